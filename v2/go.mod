@@ -10,7 +10,6 @@ require (
 	github.com/projectdiscovery/dnsx v1.1.3
 	github.com/projectdiscovery/fdmax v0.0.4
 	github.com/projectdiscovery/gologger v1.1.8
-	github.com/projectdiscovery/ratelimit v0.0.6
 	github.com/projectdiscovery/utils v0.0.16
 	github.com/rs/xid v1.4.0
 	github.com/stretchr/testify v1.8.2
@@ -0,0 +1,91 @@
+package collapse
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// StaticExtensions are the extensions, without leading dot, of the assets
+// dropped as noise. They are also the static preset of the extension filters.
+var StaticExtensions = []string{"css", "scss", "png", "jpg", "jpeg", "gif", "svg", "ico", "webp", "bmp", "tif", "tiff", "woff", "woff2", "ttf", "otf", "eot", "mp3", "mp4", "avi", "webm"}
+
+// staticExtensions is the set of StaticExtensions, with leading dot
+var staticExtensions = func() map[string]struct{} {
+	extensions := make(map[string]struct{}, len(StaticExtensions))
+	for _, extension := range StaticExtensions {
+		extensions["."+extension] = struct{}{}
+	}
+	return extensions
+}()
+
+var (
+	numericRegex = regexp.MustCompile(`^\d+$`)
+	uuidRegex    = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	hashRegex    = regexp.MustCompile(`^(?i)[0-9a-f]{16,}$`)
+	// dateRegex matches the year/month directories of blog style paths
+	dateRegex = regexp.MustCompile(`/(?:19|20)\d{2}/\d{1,2}/`)
+)
+
+// minHumanDashes is the number of dashes from which a path segment is
+// considered a slug of human written content such as a blog post title,
+// api routes such as get-user-by-id have fewer
+const minHumanDashes = 4
+
+// Pattern returns the group of the url made of its host, its path with
+// numeric, uuid and hash segments templated and its sorted parameter names.
+// It returns false for static assets and human written content, which
+// should be dropped.
+func Pattern(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return strings.ToLower(rawURL), true
+	}
+
+	if _, ok := staticExtensions[strings.ToLower(path.Ext(parsed.Path))]; ok {
+		return "", false
+	}
+	if isHumanContent(parsed.Path) {
+		return "", false
+	}
+
+	segments := strings.Split(parsed.Path, "/")
+	for i, segment := range segments {
+		switch {
+		case numericRegex.MatchString(segment):
+			segments[i] = "{int}"
+		case uuidRegex.MatchString(segment):
+			segments[i] = "{uuid}"
+		case hashRegex.MatchString(segment):
+			segments[i] = "{hash}"
+		}
+	}
+
+	names := make([]string, 0, len(parsed.Query()))
+	for name := range parsed.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pattern := strings.ToLower(parsed.Host) + strings.Join(segments, "/")
+	if len(names) > 0 {
+		pattern += "?" + strings.Join(names, "&")
+	}
+	return pattern, true
+}
+
+// isHumanContent detects paths of articles, either by a dated directory
+// followed by a slug or by a segment that looks like a sentence
+func isHumanContent(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if strings.Count(segment, "-") >= minHumanDashes && !uuidRegex.MatchString(segment) {
+			return true
+		}
+	}
+	if loc := dateRegex.FindStringIndex(urlPath); loc != nil {
+		return strings.Contains(urlPath[loc[1]:], "-")
+	}
+	return false
+}
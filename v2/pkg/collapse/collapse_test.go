@@ -0,0 +1,46 @@
+package collapse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPattern(t *testing.T) {
+	same := [][]string{
+		{"https://example.com/product?id=1", "https://example.com/product?id=2"},
+		{"https://example.com/users/12/edit", "https://example.com/users/345/edit"},
+		{"https://example.com/o/123e4567-e89b-12d3-a456-426614174000", "https://example.com/o/00000000-0000-0000-0000-000000000000"},
+		{"https://example.com/f/d41d8cd98f00b204e9800998ecf8427e", "https://example.com/f/0cc175b9c0f1b6a831c399e269772661"},
+		{"https://example.com/s?b=1&a=2", "https://Example.com/s?a=3&b=4"},
+	}
+	for _, urls := range same {
+		first, ok := Pattern(urls[0])
+		require.True(t, ok)
+		second, ok := Pattern(urls[1])
+		require.True(t, ok)
+		require.Equal(t, first, second)
+	}
+
+	first, _ := Pattern("https://example.com/product?id=1")
+	second, _ := Pattern("https://example.com/product?id=1&debug=1")
+	require.NotEqual(t, first, second)
+}
+
+func TestPatternNoise(t *testing.T) {
+	noise := []string{
+		"https://example.com/static/logo.PNG",
+		"https://example.com/fonts/a.woff2",
+		"https://example.com/blog/2019/05/post-name",
+		"https://example.com/news/how-we-built-our-api",
+	}
+	for _, value := range noise {
+		_, ok := Pattern(value)
+		require.False(t, ok, value)
+	}
+
+	for _, value := range []string{"https://example.com/api/v1/user-info", "https://example.com/api/v1/get-user-by-id"} {
+		_, ok := Pattern(value)
+		require.True(t, ok, value)
+	}
+}
@@ -0,0 +1,3 @@
+// Package collapse groups urls differing only by their
+// parameter values or identifiers in the path.
+package collapse
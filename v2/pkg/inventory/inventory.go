@@ -0,0 +1,225 @@
+// Package inventory records the urls found for each domain across runs,
+// telling which ones are new and which ones disappeared since the last run.
+// The urls are recorded by their canonical form, so that an url found in
+// another form by another run is still the same url.
+package inventory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	"golang.org/x/exp/slices"
+)
+
+// maxRuns is the number of runs kept in the history of a domain
+const maxRuns = 100
+
+// Run is an enumeration recorded in the inventory
+type Run struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Partial is true when the run may have missed some urls of the domain
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Record is an url of the inventory along with the runs which found it
+type Record struct {
+	// URL is the url as it was first found, the record is keyed by its canonical form
+	URL string `json:"url"`
+	// FirstSeen and LastSeen are the ids of the first and last runs which found the url
+	FirstSeen string `json:"first_seen"`
+	LastSeen  string `json:"last_seen"`
+	// Sources holds every source which ever found the url
+	Sources []string `json:"sources"`
+}
+
+// domainInventory is the inventory of a domain, kept in its own file
+type domainInventory struct {
+	Runs []Run              `json:"runs"`
+	URLs map[string]*Record `json:"urls"`
+}
+
+// Found is an url found by the current run along with its sources
+type Found struct {
+	URL     string
+	Sources map[string]struct{}
+}
+
+// Snapshot is the inventory of a domain as it was before the current run
+type Snapshot struct {
+	// LastRun is the previous run which enumerated the domain, empty for a new domain
+	LastRun string
+	urls    map[string]*Record
+}
+
+// Known returns true if a previous run found the url with the key
+func (s *Snapshot) Known(key string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.urls[key]
+	return ok
+}
+
+// Record returns the record of the url with the key, if a previous run found it
+func (s *Snapshot) Record(key string) (Record, bool) {
+	if s == nil {
+		return Record{}, false
+	}
+	record, ok := s.urls[key]
+	if !ok {
+		return Record{}, false
+	}
+	return *record, true
+}
+
+// Inventory records the urls found by the runs, by domain
+type Inventory struct {
+	dir string
+	// Run is the current run, recorded in the inventory of every domain it enumerates
+	Run   Run
+	mutex sync.Mutex
+}
+
+// New creates an inventory kept in the directory, the urls found are
+// recorded under a new run
+func New(dir string) *Inventory {
+	return &Inventory{dir: dir, Run: Run{ID: xid.New().String(), Time: time.Now().UTC()}}
+}
+
+// file returns the file of the inventory of the domain
+func (i *Inventory) file(domain string) string {
+	return filepath.Join(i.dir, strings.ReplaceAll(domain, string(filepath.Separator), "_")+".json")
+}
+
+// load reads the inventory of the domain, empty when it was never enumerated
+func (i *Inventory) load(domain string) (*domainInventory, error) {
+	inventory := &domainInventory{URLs: make(map[string]*Record)}
+	data, err := os.ReadFile(i.file(domain))
+	if os.IsNotExist(err) {
+		return inventory, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, inventory); err != nil {
+		return nil, err
+	}
+	if inventory.URLs == nil {
+		inventory.URLs = make(map[string]*Record)
+	}
+	return inventory, nil
+}
+
+// Snapshot returns the inventory of the domain before the current run
+func (i *Inventory) Snapshot(domain string) (*Snapshot, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	inventory, err := i.load(domain)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{LastRun: inventory.lastRun(i.Run.ID), urls: inventory.URLs}, nil
+}
+
+// lastRun returns the last run recorded before the given one
+func (d *domainInventory) lastRun(current string) string {
+	for j := len(d.Runs) - 1; j >= 0; j-- {
+		if d.Runs[j].ID != current {
+			return d.Runs[j].ID
+		}
+	}
+	return ""
+}
+
+// recentRuns returns the runs since the last complete one before the given one
+func (d *domainInventory) recentRuns(current string) map[string]struct{} {
+	runs := make(map[string]struct{})
+	for j := len(d.Runs) - 1; j >= 0; j-- {
+		if d.Runs[j].ID == current {
+			continue
+		}
+		runs[d.Runs[j].ID] = struct{}{}
+		if !d.Runs[j].Partial {
+			break
+		}
+	}
+	return runs
+}
+
+// Update records the urls found for the domain by the current run, keyed by
+// their canonical form. A complete run returns the urls found by the runs
+// since the last complete one and not by this one, as they were first found.
+// A partial run may have missed urls, it returns none.
+func (i *Inventory) Update(domain string, urls map[string]Found, complete bool) ([]string, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	inventory, err := i.load(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	if complete {
+		recent := inventory.recentRuns(i.Run.ID)
+		for key, record := range inventory.URLs {
+			if _, ok := urls[key]; ok {
+				continue
+			}
+			if _, ok := recent[record.LastSeen]; ok {
+				removed = append(removed, record.URL)
+			}
+		}
+		sort.Strings(removed)
+	}
+
+	for key, found := range urls {
+		record, ok := inventory.URLs[key]
+		if !ok {
+			record = &Record{URL: found.URL, FirstSeen: i.Run.ID}
+			inventory.URLs[key] = record
+		}
+		record.LastSeen = i.Run.ID
+		for source := range found.Sources {
+			if !slices.Contains(record.Sources, source) {
+				record.Sources = append(record.Sources, source)
+			}
+		}
+		sort.Strings(record.Sources)
+	}
+	if len(inventory.Runs) == 0 || inventory.Runs[len(inventory.Runs)-1].ID != i.Run.ID {
+		run := i.Run
+		run.Partial = !complete
+		inventory.Runs = append(inventory.Runs, run)
+	}
+	if len(inventory.Runs) > maxRuns {
+		inventory.Runs = inventory.Runs[len(inventory.Runs)-maxRuns:]
+	}
+
+	return removed, i.save(domain, inventory)
+}
+
+// save writes the inventory of the domain through a temporary file
+// so that an interruption never leaves it half written
+func (i *Inventory) save(domain string, inventory *domainInventory) error {
+	if err := os.MkdirAll(i.dir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return err
+	}
+	file := i.file(domain)
+	if err := os.WriteFile(file+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
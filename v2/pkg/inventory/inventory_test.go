@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryDiff(t *testing.T) {
+	dir := t.TempDir()
+
+	first := New(dir)
+	snapshot, err := first.Snapshot("example.com")
+	require.Nil(t, err)
+	require.Empty(t, snapshot.LastRun)
+	require.False(t, snapshot.Known("example.com/a"))
+
+	removed, err := first.Update("example.com", map[string]Found{
+		"example.com/a": {URL: "http://example.com/a", Sources: map[string]struct{}{"webarchive": {}}},
+		"example.com/b": {URL: "https://example.com/b", Sources: map[string]struct{}{"urlscan": {}}},
+	}, true)
+	require.Nil(t, err)
+	require.Empty(t, removed, "a new domain has no removed urls")
+
+	second := New(dir)
+	snapshot, err = second.Snapshot("example.com")
+	require.Nil(t, err)
+	require.Equal(t, first.Run.ID, snapshot.LastRun)
+	require.True(t, snapshot.Known("example.com/a"))
+	require.False(t, snapshot.Known("example.com/c"))
+
+	removed, err = second.Update("example.com", map[string]Found{
+		"example.com/a": {URL: "https://example.com/a", Sources: map[string]struct{}{"alienvault": {}}},
+		"example.com/c": {URL: "https://example.com/c", Sources: map[string]struct{}{"webarchive": {}}},
+	}, true)
+	require.Nil(t, err)
+	require.Equal(t, []string{"https://example.com/b"}, removed)
+
+	third := New(dir)
+	snapshot, err = third.Snapshot("example.com")
+	require.Nil(t, err)
+	require.Equal(t, second.Run.ID, snapshot.LastRun)
+	record, ok := snapshot.Record("example.com/a")
+	require.True(t, ok)
+	require.Equal(t, Record{URL: "http://example.com/a", FirstSeen: first.Run.ID, LastSeen: second.Run.ID, Sources: []string{"alienvault", "webarchive"}}, record, "the url keeps the form it was first found in")
+	record, ok = snapshot.Record("example.com/b")
+	require.True(t, ok, "the urls not found anymore are kept")
+	require.Equal(t, first.Run.ID, record.LastSeen)
+
+	// An url is reported removed only by the first run not finding it
+	removed, err = third.Update("example.com", map[string]Found{
+		"example.com/a": {URL: "https://example.com/a", Sources: map[string]struct{}{"alienvault": {}}},
+	}, true)
+	require.Nil(t, err)
+	require.Equal(t, []string{"https://example.com/c"}, removed)
+
+	snapshot, err = third.Snapshot("other.com")
+	require.Nil(t, err)
+	require.Empty(t, snapshot.LastRun, "the domains are recorded apart")
+}
+
+func TestNilSnapshot(t *testing.T) {
+	var snapshot *Snapshot
+	require.False(t, snapshot.Known("example.com/a"))
+	_, ok := snapshot.Record("example.com/a")
+	require.False(t, ok)
+}
+
+func TestInventoryPartialRun(t *testing.T) {
+	dir := t.TempDir()
+	found := func(urls ...string) map[string]Found {
+		result := make(map[string]Found)
+		for _, url := range urls {
+			result[url] = Found{URL: url, Sources: map[string]struct{}{"webarchive": {}}}
+		}
+		return result
+	}
+
+	_, err := New(dir).Update("example.com", found("a", "b", "c"), true)
+	require.Nil(t, err)
+	removed, err := New(dir).Update("example.com", found("a"), false)
+	require.Nil(t, err)
+	require.Empty(t, removed, "a partial run may have missed the urls")
+
+	// The urls found by the partial run or the last complete one are removed
+	removed, err = New(dir).Update("example.com", found("c"), true)
+	require.Nil(t, err)
+	require.Equal(t, []string{"a", "b"}, removed)
+}
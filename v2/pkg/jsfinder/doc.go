@@ -0,0 +1,3 @@
+// Package jsfinder extracts the endpoints referenced by
+// javascript files found during enumeration.
+package jsfinder
@@ -0,0 +1,146 @@
+package jsfinder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	defaultArchiveURL = "https://web.archive.org"
+	// maxScriptSize caps the amount of data read from a single javascript file
+	maxScriptSize = 5 * 1024 * 1024
+)
+
+// endpointRegex is the LinkFinder expression matching quoted absolute urls,
+// relative paths, api routes and file names with interesting extensions
+var endpointRegex = regexp.MustCompile(`(?:"|')(` +
+	`(?:[a-zA-Z]{1,10}://|//)[^"'/]{1,}\.[a-zA-Z]{2,}[^"']{0,}` +
+	`|` +
+	`(?:/|\.\./|\./)[^"'><,;| *()(%$^/\\\[\]][^"'><,;|()]{1,}` +
+	`|` +
+	`[a-zA-Z0-9_\-/]{1,}/[a-zA-Z0-9_\-/.]{1,}\.(?:[a-zA-Z]{1,4}|action)(?:[\?|#][^"|']{0,}|)` +
+	`|` +
+	`[a-zA-Z0-9_\-/]{1,}/[a-zA-Z0-9_\-/]{3,}(?:[\?|#][^"|']{0,}|)` +
+	`|` +
+	`[a-zA-Z0-9_\-]{1,}\.(?:php|asp|aspx|jsp|json|action|html|js|txt|xml)(?:[\?|#][^"|']{0,}|)` +
+	`)(?:"|')`)
+
+// mimeRegex matches content types that the route expression picks up as paths
+var mimeRegex = regexp.MustCompile(`^(?:text|application|image|audio|video|font|multipart)/[a-z0-9.+\-]+$`)
+
+// Finder downloads javascript files and extracts the endpoints they reference
+type Finder struct {
+	session *subscraping.Session
+	// ArchiveSession issues the wayback machine requests, the session of the finder when nil
+	ArchiveSession *subscraping.Session
+	// archiveURL is the root of the wayback machine, overridden in tests
+	archiveURL string
+}
+
+// New creates a finder issuing its requests through the given session
+func New(session *subscraping.Session) *Finder {
+	return &Finder{session: session, archiveURL: defaultArchiveURL}
+}
+
+// IsJavaScript returns true if the url path points at a javascript file
+func IsJavaScript(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(parsed.Path), ".js")
+}
+
+// Find downloads the script, falling back to the latest wayback snapshot when
+// the live file is gone, and returns the absolute urls it references
+func (f *Finder) Find(ctx context.Context, scriptURL string) ([]string, error) {
+	script, err := url.Parse(scriptURL)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := f.fetch(ctx, f.session, scriptURL)
+	if err != nil {
+		archiveSession := f.ArchiveSession
+		if archiveSession == nil {
+			archiveSession = f.session
+		}
+		// the wayback machine redirects the partial timestamp 2 to the latest capture,
+		// the id_ flag returning the file as archived
+		archived, archiveErr := f.fetch(ctx, archiveSession, fmt.Sprintf("%s/web/2id_/%s", f.archiveURL, scriptURL))
+		if archiveErr != nil {
+			return nil, err
+		}
+		body = archived
+	}
+
+	var urls []string
+	seen := make(map[string]struct{})
+	for _, endpoint := range Extract(body) {
+		resolved, err := Resolve(script, endpoint)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[resolved]; ok {
+			continue
+		}
+		seen[resolved] = struct{}{}
+		urls = append(urls, resolved)
+	}
+	return urls, nil
+}
+
+func (f *Finder) fetch(ctx context.Context, session *subscraping.Session, target string) ([]byte, error) {
+	resp, err := session.SimpleGet(ctx, target)
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxScriptSize))
+}
+
+// Extract returns the endpoints referenced by a javascript file as they are written
+func Extract(body []byte) []string {
+	var endpoints []string
+	for _, match := range endpointRegex.FindAllSubmatch(body, -1) {
+		endpoint := string(match[1])
+		if mimeRegex.MatchString(endpoint) {
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// Resolve turns an extracted endpoint into an absolute url. Paths relative
+// to the current directory are resolved against the script url, bare routes
+// such as api/v1/users against the origin of the script.
+func Resolve(script *url.URL, endpoint string) (string, error) {
+	if strings.HasPrefix(endpoint, "/") || strings.HasPrefix(endpoint, "./") || strings.HasPrefix(endpoint, "../") || strings.Contains(endpoint, "://") {
+		reference, err := url.Parse(endpoint)
+		if err != nil {
+			return "", err
+		}
+		resolved := script.ResolveReference(reference)
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			return "", fmt.Errorf("unsupported scheme in %s", endpoint)
+		}
+		return resolved.String(), nil
+	}
+
+	reference, err := url.Parse("/" + endpoint)
+	if err != nil {
+		return "", err
+	}
+	origin := &url.URL{Scheme: script.Scheme, Host: script.Host}
+	return origin.ResolveReference(reference).String(), nil
+}
@@ -0,0 +1,81 @@
+package jsfinder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestExtract(t *testing.T) {
+	body := []byte(`var a = "https://api.example.com/v1/users";
+fetch('/api/v2/orders?id=1');
+var b = "./chunk.js", c = "../img/logo.png";
+axios.get("api/internal/config");
+headers["Content-Type"] = "application/json";
+var d = "index.php";`)
+
+	require.Equal(t, []string{
+		"https://api.example.com/v1/users",
+		"/api/v2/orders?id=1",
+		"./chunk.js",
+		"../img/logo.png",
+		"api/internal/config",
+		"index.php",
+	}, Extract(body))
+}
+
+func TestResolve(t *testing.T) {
+	script, _ := url.Parse("https://example.com/static/js/app.js")
+	cases := map[string]string{
+		"https://api.example.com/v1": "https://api.example.com/v1",
+		"//cdn.example.com/x.js":     "https://cdn.example.com/x.js",
+		"/api/v2/orders?id=1":        "https://example.com/api/v2/orders?id=1",
+		"./chunk.js":                 "https://example.com/static/js/chunk.js",
+		"../img/logo.png":            "https://example.com/static/img/logo.png",
+		"api/internal/config":        "https://example.com/api/internal/config",
+	}
+	for endpoint, expected := range cases {
+		resolved, err := Resolve(script, endpoint)
+		require.Nil(t, err)
+		require.Equal(t, expected, resolved, endpoint)
+	}
+
+	_, err := Resolve(script, "javascript://void")
+	require.NotNil(t, err)
+}
+
+func TestFindArchiveFallback(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/web/2id_/"+server.URL+"/app.js" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprint(w, `fetch("/api/archived")`)
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+
+	// The archived script is fetched through the archive session only
+	archiveSession := *session
+	archiveSession.RateLimiter = subscraping.NewLimiter(subscraping.RateLimit{PerDay: 1})
+
+	finder := New(session)
+	finder.archiveURL = server.URL
+	finder.ArchiveSession = &archiveSession
+	urls, err := finder.Find(context.Background(), server.URL+"/app.js")
+	require.Nil(t, err)
+	require.Equal(t, []string{server.URL + "/api/archived"}, urls)
+
+	_, err = archiveSession.RateLimiter.Take(context.Background())
+	require.ErrorIs(t, err, subscraping.ErrDailyQuota)
+}
@@ -0,0 +1,3 @@
+// Package normalize turns urls into a canonical form
+// used to deduplicate the enumeration results.
+package normalize
@@ -0,0 +1,193 @@
+package normalize
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TrailingSlashPolicy decides what happens to a trailing slash of the path
+type TrailingSlashPolicy int
+
+// Policies for trailing slashes
+const (
+	// KeepTrailingSlash leaves the path as found, /a and /a/ stay distinct
+	KeepTrailingSlash TrailingSlashPolicy = iota
+	// StripTrailingSlash removes the trailing slash of every path but the root
+	StripTrailingSlash
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer normalizes urls following RFC 3986 section 6
+type Normalizer struct {
+	// TrailingSlash is the policy applied to trailing slashes
+	TrailingSlash TrailingSlashPolicy
+	// KeepScheme keeps the scheme in the keys, otherwise the http and
+	// https versions of an url share the same key
+	KeepScheme bool
+}
+
+// Normalize returns the canonical form of the url. The scheme and host are
+// lowercased, the default port, dot segments and fragment are removed,
+// percent-encodings are normalized and the query parameters sorted by name.
+// Urls without a scheme are read as http urls.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + strings.TrimPrefix(rawURL, "//")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	if port := parsed.Port(); port != "" && port != defaultPorts[scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// keep the brackets of ipv6 hosts
+		host = "[" + host + "]"
+	}
+
+	path := removeDotSegments(normalizeEscapes(parsed.EscapedPath()))
+	if path == "" {
+		path = "/"
+	}
+	if n.TrailingSlash == StripTrailingSlash && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString(scheme)
+	builder.WriteString("://")
+	if parsed.User != nil {
+		builder.WriteString(parsed.User.String())
+		builder.WriteString("@")
+	}
+	builder.WriteString(host)
+	builder.WriteString(path)
+	if query := normalizeQuery(parsed.RawQuery); query != "" {
+		builder.WriteString("?")
+		builder.WriteString(query)
+	}
+	return builder.String(), nil
+}
+
+// Key returns the deduplication key of the url, which is its canonical form
+// without the scheme unless KeepScheme is set. Urls that can't be parsed are
+// keyed by their lowercased value.
+func (n *Normalizer) Key(rawURL string) string {
+	normalized, err := n.Normalize(rawURL)
+	if err != nil {
+		return strings.ToLower(rawURL)
+	}
+	if !n.KeepScheme {
+		if i := strings.Index(normalized, "://"); i >= 0 {
+			return normalized[i+3:]
+		}
+	}
+	return normalized
+}
+
+// normalizeQuery sorts the parameters by name, keeping the order of repeated
+// names, and drops empty parameters
+func normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param != "" {
+			params = append(params, normalizeEscapes(param))
+		}
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return paramName(params[i]) < paramName(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+func paramName(param string) string {
+	name, _, _ := strings.Cut(param, "=")
+	return name
+}
+
+// normalizeEscapes decodes the percent-encoded unreserved characters and
+// uppercases the hex digits of the remaining escapes
+func normalizeEscapes(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	builder := &strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]) {
+			decoded := unhex(value[i+1])<<4 | unhex(value[i+2])
+			if isUnreserved(decoded) {
+				builder.WriteByte(decoded)
+			} else {
+				builder.WriteByte('%')
+				builder.WriteString(strings.ToUpper(value[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+// removeDotSegments implements the algorithm of RFC 3986 section 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	var output []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	return strings.Join(output, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
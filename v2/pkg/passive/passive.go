@@ -3,10 +3,13 @@ package passive
 import (
 	"context"
 	"fmt"
-	"sort"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/projectdiscovery/gologger"
+
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
@@ -15,7 +18,8 @@ func (a *Agent) EnumerateURLs(domain string, proxy string, rateLimit, timeout in
 	return a.EnumerateURLsWithCtx(context.Background(), domain, proxy, rateLimit, timeout, maxEnumTime)
 }
 
-// EnumerateURLsWithCtx enumerates all the urls for a given domain
+// EnumerateURLsWithCtx enumerates all the urls for a given domain.
+// It is safe to enumerate several domains concurrently with the same agent.
 func (a *Agent) EnumerateURLsWithCtx(ctx context.Context, domain string, proxy string, rateLimit, timeout int, maxEnumTime time.Duration) chan subscraping.Result {
 	results := make(chan subscraping.Result)
 	go func() {
@@ -28,35 +32,158 @@ func (a *Agent) EnumerateURLsWithCtx(ctx context.Context, domain string, proxy s
 			}
 			return
 		}
+		session.PageSize = a.PageSize
+		session.MaxPages = a.MaxPages
+		session.MaxIndexes = a.MaxIndexes
+		session.YearFrom = a.YearFrom
+		session.YearTo = a.YearTo
+		session.Dates = a.Dates
+		if a.Keys != nil {
+			session.Keys = a.Keys
+		}
 
 		ctx, cancel := context.WithTimeout(ctx, maxEnumTime)
 
+		stats := make(map[string]subscraping.Statistics, len(a.sources))
+		statsMutex := &sync.Mutex{}
 		wg := &sync.WaitGroup{}
+		progress := subscraping.ProgressFrom(ctx)
 		// Run each source in parallel on the target domain
 		for _, runner := range a.sources {
+			// A resumed enumeration skips the sources already done
+			if progress.Done[runner.Name()] {
+				continue
+			}
 			wg.Add(1)
 
-			go func(source subscraping.Source) {
-				for resp := range source.Run(ctx, domain, session) {
-					results <- resp
+			// Every source gets its own copy of the session, rate limited
+			// by the limiter it shares with the other domains being enumerated
+			sourceSession := *session
+			sourceSession.RateLimiter = a.SourceLimiter(runner, rateLimit)
+
+			go func(source subscraping.Source, session *subscraping.Session) {
+				sourceStats := a.runSource(ctx, source, domain, session, results)
+				// A source stopped by the timeout or an error has more results
+				if subscraping.Resumable(ctx) && ctx.Err() == nil && sourceStats.Errors == 0 && !sourceStats.Skipped {
+					results <- subscraping.Result{Source: source.Name(), Type: subscraping.Done}
 				}
+				statsMutex.Lock()
+				stats[source.Name()] = sourceStats
+				statsMutex.Unlock()
 				wg.Done()
-			}(runner)
+			}(newSourceInstance(runner), &sourceSession)
 		}
 		wg.Wait()
 		cancel()
+
+		a.mutex.Lock()
+		a.statistics[domain] = stats
+		a.mutex.Unlock()
 	}()
 	return results
 }
 
-func (a *Agent) GetStatistics() map[string]subscraping.Statistics {
-	stats := make(map[string]subscraping.Statistics)
-	sort.Slice(a.sources, func(i, j int) bool {
-		return a.sources[i].Name() > a.sources[j].Name()
-	})
+// runSource sends the results of the source for the domain, read from the
+// cache when they were cached recently enough, and returns its statistics
+func (a *Agent) runSource(ctx context.Context, source subscraping.Source, domain string, session *subscraping.Session, results chan subscraping.Result) subscraping.Statistics {
+	name := source.Name()
+	// A resumed source returns a part of its results only, which is not cached
+	if a.Cache == nil || !a.Cache.Enabled(name) || subscraping.ResumeCursor(ctx, name) != "" {
+		for resp := range source.Run(ctx, domain, session) {
+			results <- resp
+		}
+		sourceStats := source.Statistics()
+		sourceStats.Retries = session.Retries()
+		return sourceStats
+	}
+
+	key := subscraping.CacheKey(name, domain, session)
+	if cached, ok := a.Cache.Load(name, key); ok {
+		for _, result := range cached {
+			results <- result
+		}
+		return subscraping.Statistics{Results: len(cached), Cache: subscraping.CacheHit}
+	}
+
+	writer, err := a.Cache.Writer(name, key)
+	if err != nil {
+		gologger.Warning().Msgf("Could not cache the results of %s: %s\n", name, err)
+	}
+	for resp := range source.Run(ctx, domain, session) {
+		if writer != nil && resp.Type == subscraping.URL {
+			writer.Write(resp)
+		}
+		results <- resp
+	}
+	sourceStats := source.Statistics()
+	sourceStats.Retries = session.Retries()
+	sourceStats.Cache = subscraping.CacheMiss
+	if writer == nil {
+		return sourceStats
+	}
+	// Only the complete results are cached
+	if ctx.Err() != nil || sourceStats.Errors > 0 || sourceStats.Skipped {
+		writer.Discard()
+	} else if err := writer.Commit(); err != nil {
+		gologger.Warning().Msgf("Could not cache the results of %s: %s\n", name, err)
+	}
+	return sourceStats
+}
+
+// GetStatistics returns the statistics of each source for the last enumeration of the domain
+func (a *Agent) GetStatistics(domain string) map[string]subscraping.Statistics {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.statistics[domain]
+}
+
+// SourceLimiter returns the rate limiter of the source, shared by every
+// domain enumerated with the agent, within the global rate limit
+func (a *Agent) SourceLimiter(source subscraping.Source, rateLimit int) *subscraping.Limiter {
+	global := a.GlobalLimiter(rateLimit)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	limiter, ok := a.limiters[source.Name()]
+	if !ok {
+		limiter = subscraping.NewChildLimiter(a.SourceRateLimit(source), global)
+		a.limiters[source.Name()] = limiter
+	}
+	return limiter
+}
+
+// GlobalLimiter returns the limiter of the requests of all the sources
+// together, rateLimit being the requests per second of the whole run
+func (a *Agent) GlobalLimiter(rateLimit int) *subscraping.Limiter {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.global == nil {
+		a.global = subscraping.NewLimiter(subscraping.RateLimit{PerSecond: float64(rateLimit)})
+	}
+	return a.global
+}
+
+// SourceRateLimit returns the rate limit of the source: its own default
+// replaced by the configured overrides
+func (a *Agent) SourceRateLimit(source subscraping.Source) subscraping.RateLimit {
+	var limit subscraping.RateLimit
+	if rateLimited, ok := source.(subscraping.RateLimited); ok {
+		limit = rateLimited.RateLimit()
+	}
+	return limit.Merge(a.RateLimits[strings.ToLower(source.Name())])
+}
 
-	for _, source := range a.sources {
-		stats[source.Name()] = source.Statistics()
+// newSourceInstance returns a shallow copy of the source, sources keep
+// their statistics on themselves so each enumeration runs its own copy
+func newSourceInstance(source subscraping.Source) subscraping.Source {
+	value := reflect.ValueOf(source)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return source
 	}
-	return stats
+	instance := reflect.New(value.Elem().Type())
+	instance.Elem().Set(value.Elem())
+	return instance.Interface().(subscraping.Source)
 }
@@ -0,0 +1,219 @@
+package passive
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+// countingSource emits one url per label of its key list and keeps its
+// statistics on itself like the real sources
+type countingSource struct {
+	keys    []string
+	results int
+	// failing reports an error after the urls
+	failing bool
+	errors  int
+}
+
+func (s *countingSource) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.results = 0
+	s.errors = 0
+	go func() {
+		defer close(results)
+		for _, key := range s.keys {
+			release, err := session.RateLimiter.Take(ctx)
+			if err != nil {
+				return
+			}
+			release()
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: key + "." + domain}
+			s.results++
+		}
+		if s.failing {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: errors.New("throttled")}
+			s.errors++
+		}
+	}()
+	return results
+}
+
+func (s *countingSource) Name() string              { return "counting" }
+func (s *countingSource) IsDefault() bool           { return true }
+func (s *countingSource) HasRecursiveSupport() bool { return false }
+func (s *countingSource) NeedsKey() bool            { return false }
+func (s *countingSource) AddApiKeys(keys []string)  { s.keys = keys }
+func (s *countingSource) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{Results: s.results, Errors: s.errors}
+}
+
+func TestEnumerateURLsConcurrently(t *testing.T) {
+	source := &countingSource{}
+	source.AddApiKeys([]string{"a", "b", "c"})
+	agent := &Agent{
+		sources:    []subscraping.Source{source},
+		limiters:   make(map[string]*subscraping.Limiter),
+		statistics: make(map[string]map[string]subscraping.Statistics),
+	}
+
+	domains := []string{"example.com", "example.org", "example.net"}
+	found := make(map[string][]string)
+	mutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for _, domain := range domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			for result := range agent.EnumerateURLs(domain, "", 0, 10, time.Minute) {
+				mutex.Lock()
+				found[domain] = append(found[domain], result.Value)
+				mutex.Unlock()
+			}
+		}(domain)
+	}
+	wg.Wait()
+
+	for _, domain := range domains {
+		require.ElementsMatch(t, []string{"a." + domain, "b." + domain, "c." + domain}, found[domain])
+		require.Equal(t, 3, agent.GetStatistics(domain)["counting"].Results)
+	}
+	require.Len(t, agent.limiters, 1, "the sources rate limiter should be shared by the domains")
+	require.Zero(t, source.results, "the registered source should not be run directly")
+}
+
+// limitedSource declares a default rate limit
+type limitedSource struct {
+	countingSource
+}
+
+func (s *limitedSource) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 2, PerDay: 500}
+}
+
+func TestSourceRateLimit(t *testing.T) {
+	agent := &Agent{}
+	source := &limitedSource{}
+
+	require.Equal(t, subscraping.RateLimit{PerSecond: 2, PerDay: 500}, agent.SourceRateLimit(source))
+	require.Equal(t, subscraping.RateLimit{}, agent.SourceRateLimit(&countingSource{}))
+
+	// The overrides replace the default
+	agent.RateLimits = map[string]subscraping.RateLimit{"counting": {PerSecond: 5, MaxInFlight: 1}}
+	require.Equal(t, subscraping.RateLimit{PerSecond: 5, PerDay: 500, MaxInFlight: 1}, agent.SourceRateLimit(source))
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	agent := &Agent{limiters: make(map[string]*subscraping.Limiter)}
+	global := agent.GlobalLimiter(1)
+	require.Equal(t, subscraping.RateLimit{PerSecond: 1}, global.Limit())
+
+	// The requests of every source are counted by the global limiter
+	ctx := context.Background()
+	release, err := agent.SourceLimiter(&countingSource{}, 1).Take(ctx)
+	require.Nil(t, err)
+	release()
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = agent.SourceLimiter(&otherSource{}, 1).Take(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "the request of another source waits for the global limit")
+	require.Len(t, agent.limiters, 2)
+}
+
+// otherSource is a source distinct from countingSource
+type otherSource struct {
+	countingSource
+}
+
+func (s *otherSource) Name() string { return "other" }
+
+func TestEnumerateURLsResumed(t *testing.T) {
+	done := &countingSource{}
+	done.AddApiKeys([]string{"a"})
+	agent := &Agent{
+		sources:    []subscraping.Source{done},
+		limiters:   make(map[string]*subscraping.Limiter),
+		statistics: make(map[string]map[string]subscraping.Statistics),
+	}
+
+	var types []subscraping.ResultType
+	for result := range agent.EnumerateURLs("example.com", "", 0, 10, time.Minute) {
+		types = append(types, result.Type)
+	}
+	require.Equal(t, []subscraping.ResultType{subscraping.URL}, types, "done results are only returned when resumable")
+
+	types = nil
+	ctx := subscraping.WithProgress(context.Background(), subscraping.Progress{})
+	for result := range agent.EnumerateURLsWithCtx(ctx, "example.com", "", 0, 10, time.Minute) {
+		types = append(types, result.Type)
+	}
+	require.Equal(t, []subscraping.ResultType{subscraping.URL, subscraping.Done}, types)
+
+	ctx = subscraping.WithProgress(context.Background(), subscraping.Progress{Done: map[string]bool{"counting": true}})
+	for result := range agent.EnumerateURLsWithCtx(ctx, "example.com", "", 0, 10, time.Minute) {
+		t.Fatalf("unexpected result of a done source: %v", result)
+	}
+}
+
+func TestEnumerateURLsCached(t *testing.T) {
+	source := &countingSource{}
+	source.AddApiKeys([]string{"a", "b"})
+	agent := &Agent{
+		sources:    []subscraping.Source{source},
+		limiters:   make(map[string]*subscraping.Limiter),
+		statistics: make(map[string]map[string]subscraping.Statistics),
+		Cache:      subscraping.NewCache(t.TempDir()),
+	}
+
+	enumerate := func() []string {
+		var found []string
+		for result := range agent.EnumerateURLs("example.com", "", 0, 10, time.Minute) {
+			found = append(found, result.Value)
+		}
+		return found
+	}
+
+	require.Equal(t, []string{"a.example.com", "b.example.com"}, enumerate())
+	require.Equal(t, subscraping.CacheMiss, agent.GetStatistics("example.com")["counting"].Cache)
+
+	// The source is not run again while its results are cached
+	source.AddApiKeys([]string{"c"})
+	require.Equal(t, []string{"a.example.com", "b.example.com"}, enumerate())
+	stats := agent.GetStatistics("example.com")["counting"]
+	require.Equal(t, subscraping.CacheHit, stats.Cache)
+	require.Equal(t, 2, stats.Results)
+
+	agent.Cache.Refresh = true
+	require.Equal(t, []string{"c.example.com"}, enumerate())
+}
+
+func TestEnumerateURLsNotCachedOnError(t *testing.T) {
+	source := &countingSource{failing: true}
+	source.AddApiKeys([]string{"a"})
+	agent := &Agent{
+		sources:    []subscraping.Source{source},
+		limiters:   make(map[string]*subscraping.Limiter),
+		statistics: make(map[string]map[string]subscraping.Statistics),
+		Cache:      subscraping.NewCache(t.TempDir()),
+	}
+
+	for range agent.EnumerateURLs("example.com", "", 0, 10, time.Minute) {
+	}
+	require.Equal(t, subscraping.CacheMiss, agent.GetStatistics("example.com")["counting"].Cache)
+
+	// The partial results of the failed run are not read back
+	source.AddApiKeys([]string{"b"})
+	source.failing = false
+	var found []string
+	for result := range agent.EnumerateURLs("example.com", "", 0, 10, time.Minute) {
+		found = append(found, result.Value)
+	}
+	require.Equal(t, []string{"b.example.com"}, found)
+	require.Equal(t, subscraping.CacheMiss, agent.GetStatistics("example.com")["counting"].Cache)
+}
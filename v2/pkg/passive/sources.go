@@ -4,8 +4,14 @@ import (
 	"fmt"
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/alienvault"
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/bevigil"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/commoncrawl"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/sitemap"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/urlscan"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/virustotal"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/waybackrobots"
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/webarchive"
 	"strings"
+	"sync"
 
 	"golang.org/x/exp/maps"
 
@@ -15,8 +21,13 @@ import (
 
 var AllSources = [...]subscraping.Source{
 	&webarchive.Source{},
+	&waybackrobots.Source{},
 	&alienvault.Source{},
 	&bevigil.Source{},
+	&commoncrawl.Source{},
+	&urlscan.Source{},
+	&virustotal.Source{},
+	&sitemap.Source{},
 	//&baidu.Source{},
 	//&github.Source{}, //没效果，暂定
 	// &threatminer.Source{}, // failing  api
@@ -36,6 +47,30 @@ func init() {
 // a layer to build upon.
 type Agent struct {
 	sources []subscraping.Source
+	// limiters holds the rate limiter of each source
+	limiters map[string]*subscraping.Limiter
+	// global is the limiter of the requests of all the sources together
+	global *subscraping.Limiter
+	// statistics holds the source statistics of each enumerated domain
+	statistics map[string]map[string]subscraping.Statistics
+	mutex      sync.Mutex
+	// PageSize is the page size requested from paginated sources
+	PageSize int
+	// MaxPages is the maximum number of pages fetched from paginated sources
+	MaxPages int
+	// MaxIndexes is the number of crawl indexes queried by index based sources
+	MaxIndexes int
+	// YearFrom and YearTo restrict the crawl indexes to a year range
+	YearFrom int
+	YearTo   int
+	// Dates restricts the results of the sources to a date range
+	Dates subscraping.DateRange
+	// RateLimits overrides the default rate limit of the sources, by source name
+	RateLimits map[string]subscraping.RateLimit
+	// Keys tracks the health of the api keys of the sources across domains
+	Keys *subscraping.KeyManager
+	// Cache keeps the results of the sources across runs, nil disables it
+	Cache *subscraping.Cache
 }
 
 // New creates a new agent for passive url discovery
@@ -79,7 +114,11 @@ func New(sourceNames, excludedSourceNames []string, useAllSources, useSourcesSup
 	gologger.Debug().Msgf(fmt.Sprintf("Selected source(s) for this search: %s", strings.Join(maps.Keys(sources), ", ")))
 
 	// Create the agent, insert the sources and remove the excluded sources
-	agent := &Agent{sources: maps.Values(sources)}
+	agent := &Agent{
+		sources:    maps.Values(sources),
+		limiters:   make(map[string]*subscraping.Limiter),
+		statistics: make(map[string]map[string]subscraping.Statistics),
+	}
 
 	return agent
 }
@@ -22,6 +22,8 @@ var DefaultResolvers = []string{
 type Resolver struct {
 	DNSClient *dnsx.DNSX
 	Resolvers []string
+	// Prober is used to probe the found urls, a default one is created when nil
+	Prober *Prober
 }
 
 // New creates a new resolver struct with the default resolvers
@@ -0,0 +1,136 @@
+package resolve
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/corpix/uarand"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// maxProbeBodySize caps the amount of the response read to find the title
+	maxProbeBodySize = 512 * 1024
+	maxRedirects     = 10
+)
+
+var TitleRegexp = regexp.MustCompile("(?Uis)<title>(.*)</title>")
+
+// Prober issues a single http request per url and records the response details
+type Prober struct {
+	client      *http.Client
+	rateLimiter *subscraping.Limiter
+}
+
+// ProbeResult contains the details of a probed url
+type ProbeResult struct {
+	StatusCode    int
+	Title         string
+	ContentLength int64
+	ContentType   string
+	FinalURL      string
+	RedirectChain []string
+}
+
+// NewProber creates a prober with a shared transport honoring the proxy, timeout in seconds
+// and requests per second, zero rateLimit meaning unlimited
+func NewProber(proxy string, timeout, rateLimit int) *Prober {
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+		DialContext: (&net.Dialer{
+			Timeout: time.Duration(timeout) * time.Second,
+		}).DialContext,
+	}
+
+	if proxy != "" {
+		proxyURL, _ := url.Parse(proxy)
+		if proxyURL == nil {
+			// Log warning but continue anyway
+			gologger.Warning().Msgf("Invalid proxy provided: %s", proxy)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(timeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	return &Prober{
+		client:      client,
+		rateLimiter: subscraping.NewLimiter(subscraping.RateLimit{PerSecond: float64(rateLimit)}),
+	}
+}
+
+// Probe requests the url once, following redirects, and returns the details of the final response
+func (p *Prober) Probe(ctx context.Context, target string) (*ProbeResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", uarand.GetRandom())
+	req.Header.Set("Accept", "*/*")
+
+	release, err := p.rateLimiter.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
+	if err != nil {
+		return nil, err
+	}
+	// drain what is left so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	result := &ProbeResult{
+		StatusCode:    resp.StatusCode,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+		FinalURL:      resp.Request.URL.String(),
+		RedirectChain: redirectChain(resp),
+	}
+	if result.ContentLength < 0 {
+		result.ContentLength = int64(len(body))
+	}
+	if titleMatches := TitleRegexp.FindSubmatch(body); len(titleMatches) > 1 {
+		result.Title = strings.TrimSpace(string(titleMatches[1]))
+	}
+	return result, nil
+}
+
+// redirectChain walks back the responses that led to the final one and
+// returns the requested urls in order, it is empty without redirects
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for previous := resp.Request.Response; previous != nil; previous = previous.Request.Response {
+		chain = append([]string{previous.Request.URL.String()}, chain...)
+	}
+	return chain
+}
@@ -0,0 +1,56 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, "<html><title> Final page </title></html>")
+		}
+	}))
+	defer server.Close()
+
+	result, err := NewProber("", 10, 0).Probe(context.Background(), server.URL+"/old")
+	require.Nil(t, err)
+	require.Equal(t, 3, requests, "every url of the chain should be requested once")
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "Final page", result.Title)
+	require.Equal(t, "text/html", result.ContentType)
+	require.Equal(t, int64(40), result.ContentLength)
+	require.Equal(t, server.URL+"/final", result.FinalURL)
+	require.Equal(t, []string{server.URL + "/old", server.URL + "/new"}, result.RedirectChain)
+}
+
+func TestProbeCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The second probe waits a second for the rate limiter unless canceled
+	prober := NewProber("", 10, 1)
+	_, err := prober.Probe(context.Background(), server.URL)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = prober.Probe(ctx, server.URL)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
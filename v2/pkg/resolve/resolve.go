@@ -1,18 +1,19 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"regexp"
 	"strconv"
 	"sync"
 
 	"github.com/rs/xid"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
 const (
-	maxWildcardChecks = 3
+	maxWildcardChecks   = 3
+	defaultProbeTimeout = 30
 )
 
 // ResolutionPool is a pool of resolvers created for resolving urls
@@ -31,6 +32,10 @@ type ResolutionPool struct {
 type HostEntry struct {
 	Host   string
 	Source string
+	// Count is the number of urls collapsed into this one, zero when not collapsing
+	Count int
+	// Metadata merges what the sources know about the url, nil when they know nothing
+	Metadata *subscraping.Metadata
 }
 
 // Result contains the result for a host resolution
@@ -42,6 +47,15 @@ type Result struct {
 	Source     string
 	StatusCode string
 	UrlTitle   string
+	// Details of the probed response, the redirect chain
+	// lists the urls requested before the final one
+	ContentLength int64
+	ContentType   string
+	FinalURL      string
+	RedirectChain []string
+	// Count and Metadata are the ones of the resolved host entry
+	Count    int
+	Metadata *subscraping.Metadata
 }
 
 // ResultType is the type of result found
@@ -53,8 +67,9 @@ const (
 	Error
 )
 
-// NewResolutionPool creates a pool of resolvers for resolving urls of a given domain
-func (r *Resolver) NewResolutionPool(workers int, removeWildcard bool) *ResolutionPool {
+// NewResolutionPool creates a pool of resolvers for resolving urls of a given domain,
+// the probes stop when the context of the enumeration is done
+func (r *Resolver) NewResolutionPool(ctx context.Context, workers int, removeWildcard bool) *ResolutionPool {
 	resolutionPool := &ResolutionPool{
 		Resolver:       r,
 		Tasks:          make(chan HostEntry),
@@ -67,7 +82,7 @@ func (r *Resolver) NewResolutionPool(workers int, removeWildcard bool) *Resoluti
 	go func() {
 		for i := 0; i < workers; i++ {
 			resolutionPool.wg.Add(1)
-			go resolutionPool.resolveWorker()
+			go resolutionPool.resolveWorker(ctx)
 		}
 		resolutionPool.wg.Wait()
 		close(resolutionPool.Results)
@@ -94,58 +109,29 @@ func (r *ResolutionPool) InitWildcards(domain string) error {
 	return nil
 }
 
-func GetStatus(domain string) (int, error) {
-	resp, err := http.Get(fmt.Sprintf("%s", domain))
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	return resp.StatusCode, nil
-}
-
-var TitleRegexp = regexp.MustCompile("(?Uis)<title>(.*)</title>")
-
-func GetTitle(domain string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s", domain))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+func (r *ResolutionPool) resolveWorker(ctx context.Context) {
+	prober := r.Prober
+	if prober == nil {
+		prober = NewProber("", defaultProbeTimeout, 0)
 	}
-
-	titleMatches := TitleRegexp.FindStringSubmatch(string(body))
-	if len(titleMatches) > 1 {
-		return titleMatches[1], nil
-	}
-
-	return "", nil
-}
-
-func (r *ResolutionPool) resolveWorker() {
 	for task := range r.Tasks {
-		//Get urls title
-		title, err := GetTitle(task.Host)
-		if err != nil {
-			r.Results <- Result{Type: Error, Host: task.Host, Source: task.Source, Error: err}
-			continue
-		}
-		//Get Url status code
-		status, err := GetStatus(task.Host)
+		probe, err := prober.Probe(ctx, task.Host)
 		if err != nil {
-			r.Results <- Result{Type: Error, Host: task.Host, Source: task.Source, Error: err}
+			r.Results <- Result{Type: Error, Host: task.Host, Source: task.Source, Error: err, Count: task.Count, Metadata: task.Metadata}
 			continue
 		}
 		r.Results <- Result{
-			Type:       URL,
-			Host:       task.Host,
-			UrlTitle:   title,
-			StatusCode: strconv.Itoa(status),
-			Source:     task.Source,
+			Type:          URL,
+			Host:          task.Host,
+			UrlTitle:      probe.Title,
+			StatusCode:    strconv.Itoa(probe.StatusCode),
+			Source:        task.Source,
+			ContentLength: probe.ContentLength,
+			ContentType:   probe.ContentType,
+			FinalURL:      probe.FinalURL,
+			RedirectChain: probe.RedirectChain,
+			Count:         task.Count,
+			Metadata:      task.Metadata,
 		}
 	}
 	r.wg.Done()
@@ -0,0 +1,274 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+// checkpointInterval is how often the checkpoint is written during the enumeration
+const checkpointInterval = 30 * time.Second
+
+// checkpoint is the progress of an enumeration saved to resume it when it
+// is interrupted. A nil checkpoint records nothing.
+type checkpoint struct {
+	mutex sync.Mutex
+	file  string
+	// dirty is true when the checkpoint changed since it was last written
+	dirty bool
+	// Domains holds the state of the domains enumerated so far
+	Domains map[string]*domainState `json:"domains"`
+}
+
+// domainState is the progress of the enumeration of a domain
+type domainState struct {
+	// Done is true once every result of the domain was written
+	Done     bool                 `json:"done,omitempty"`
+	Progress subscraping.Progress `json:"progress"`
+	// URLs holds the urls found by the enumeration, by url. When streaming
+	// they were written already, otherwise they are written once the domain
+	// is done.
+	URLs map[string]*urlState `json:"urls,omitempty"`
+}
+
+// urlState is an url found by an interrupted enumeration
+type urlState struct {
+	Source   string                `json:"source"`
+	Sources  []string              `json:"sources,omitempty"`
+	Count    int                   `json:"count,omitempty"`
+	Metadata *subscraping.Metadata `json:"metadata,omitempty"`
+}
+
+// loadCheckpoint reads the checkpoint of a previous run from the file,
+// a missing file starts a new checkpoint
+func loadCheckpoint(file string) (*checkpoint, error) {
+	c := &checkpoint{file: file, Domains: make(map[string]*domainState)}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.Domains == nil {
+		c.Domains = make(map[string]*domainState)
+	}
+	return c, nil
+}
+
+// save writes the checkpoint if it changed, through a temporary file so
+// that an interruption never leaves it half written
+func (c *checkpoint) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.file); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	temp := c.file + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, c.file); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// saveEvery writes the checkpoint periodically and once more when the
+// context is done, right away on an interruption
+func (c *checkpoint) saveEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if err := c.save(); err != nil {
+			gologger.Warning().Msgf("Could not save checkpoint to %s: %s\n", c.file, err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// remove deletes the checkpoint file once the whole enumeration is done
+func (c *checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.dirty = false
+	if err := os.Remove(c.file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// domain returns the state of the domain, creating it if needed
+func (c *checkpoint) domain(domain string) *domainState {
+	state, ok := c.Domains[domain]
+	if !ok {
+		state = &domainState{}
+		c.Domains[domain] = state
+	}
+	return state
+}
+
+// isDone returns true if the domain was enumerated by a previous run
+func (c *checkpoint) isDone(domain string) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state, ok := c.Domains[domain]
+	return ok && state.Done
+}
+
+// isStarted returns true if a previous run found urls for the domain
+func (c *checkpoint) isStarted(domain string) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state, ok := c.Domains[domain]
+	return ok && len(state.URLs) > 0
+}
+
+// progress returns a copy of the progress of the sources on the domain
+func (c *checkpoint) progress(domain string) subscraping.Progress {
+	progress := subscraping.Progress{Cursors: make(map[string]string), Done: make(map[string]bool)}
+	if c == nil {
+		return progress
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if state, ok := c.Domains[domain]; ok {
+		for source, cursor := range state.Progress.Cursors {
+			progress.Cursors[source] = cursor
+		}
+		for source, done := range state.Progress.Done {
+			progress.Done[source] = done
+		}
+	}
+	return progress
+}
+
+// urls returns the urls found for the domain by a previous run
+func (c *checkpoint) urls(domain string) map[string]urlState {
+	urls := make(map[string]urlState)
+	if c == nil {
+		return urls
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if state, ok := c.Domains[domain]; ok {
+		for url, entry := range state.URLs {
+			urls[url] = *entry
+		}
+	}
+	return urls
+}
+
+// recordCursor records the position of the next page of the source
+func (c *checkpoint) recordCursor(domain, source, cursor string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.domain(domain)
+	if state.Progress.Cursors == nil {
+		state.Progress.Cursors = make(map[string]string)
+	}
+	state.Progress.Cursors[source] = cursor
+	c.dirty = true
+}
+
+// recordSourceDone records that the source returned all of its results
+func (c *checkpoint) recordSourceDone(domain, source string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.domain(domain)
+	if state.Progress.Done == nil {
+		state.Progress.Done = make(map[string]bool)
+	}
+	state.Progress.Done[source] = true
+	delete(state.Progress.Cursors, source)
+	c.dirty = true
+}
+
+// recordURL records the url found for the domain along with its sources
+func (c *checkpoint) recordURL(domain string, entry resolve.HostEntry, sources map[string]struct{}) {
+	if c == nil {
+		return
+	}
+	names := make([]string, 0, len(sources))
+	for source := range sources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := c.domain(domain)
+	if state.URLs == nil {
+		state.URLs = make(map[string]*urlState)
+	}
+	state.URLs[entry.Host] = &urlState{Source: entry.Source, Sources: names, Count: entry.Count, Metadata: entry.Metadata}
+	c.dirty = true
+}
+
+// complete records that the domain was enumerated and written, its
+// progress is dropped since it is not resumed anymore
+func (c *checkpoint) complete(domain string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Domains[domain] = &domainState{Done: true}
+	c.dirty = true
+}
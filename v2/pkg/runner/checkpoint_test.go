@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "resume.json")
+
+	c, err := loadCheckpoint(file)
+	require.Nil(t, err)
+	require.Nil(t, c.save(), "an unchanged checkpoint is not written")
+	_, err = os.Stat(file)
+	require.True(t, os.IsNotExist(err))
+
+	metadata := &subscraping.Metadata{FirstSeen: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Hits: 2}
+	c.recordCursor("example.com", "webarchive", "key-1")
+	c.recordCursor("example.com", "urlscan", "1,2")
+	c.recordSourceDone("example.com", "urlscan")
+	c.recordURL("example.com", resolve.HostEntry{Host: "https://example.com/a", Source: "webarchive", Metadata: metadata},
+		map[string]struct{}{"webarchive": {}, "alienvault": {}})
+	c.complete("example.org")
+	require.Nil(t, c.save())
+
+	resumed, err := loadCheckpoint(file)
+	require.Nil(t, err)
+	require.True(t, resumed.isDone("example.org"))
+	require.False(t, resumed.isDone("example.com"))
+	require.True(t, resumed.isStarted("example.com"))
+	require.Equal(t, subscraping.Progress{
+		Cursors: map[string]string{"webarchive": "key-1"},
+		Done:    map[string]bool{"urlscan": true},
+	}, resumed.progress("example.com"))
+	require.Equal(t, map[string]urlState{
+		"https://example.com/a": {Source: "webarchive", Sources: []string{"alienvault", "webarchive"}, Metadata: metadata},
+	}, resumed.urls("example.com"))
+
+	resumed.complete("example.com")
+	require.Empty(t, resumed.urls("example.com"), "a done domain is not resumed")
+	require.Nil(t, resumed.remove())
+	_, err = os.Stat(file)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestNilCheckpoint(t *testing.T) {
+	var c *checkpoint
+	c.recordCursor("example.com", "webarchive", "key-1")
+	c.recordURL("example.com", resolve.HostEntry{Host: "https://example.com/a"}, nil)
+	c.complete("example.com")
+	require.False(t, c.isDone("example.com"))
+	require.Empty(t, c.urls("example.com"))
+	require.Nil(t, c.save())
+}
@@ -1,12 +1,15 @@
 package runner
 
 import (
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/chainreactors/urlfounder/v2/pkg/passive"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 	"github.com/projectdiscovery/gologger"
 )
 
@@ -40,16 +43,24 @@ func CreateProviderConfigYAML(configFilePath string, sourcesRequiringApiKeysMap
 	return yaml.NewEncoder(configFile).Encode(sourcesRequiringApiKeysMap)
 }
 
+const (
+	// rateLimitsKey is the provider config entry overriding the rate limit of the sources
+	rateLimitsKey = "rate-limits"
+	// cacheTTLKey is the provider config entry overriding the cache ttl of the sources
+	cacheTTLKey = "cache-ttl"
+)
+
 // UnmarshalFrom writes the marshaled yaml config to disk
 func UnmarshalFrom(file string) error {
-	f, err := os.Open(file)
-	if err != nil {
+	configs, err := readProviderConfig(file)
+	if isFatalErr(err) {
 		return err
 	}
-	defer f.Close()
 
-	sourceApiKeysMap := map[string][]string{}
-	err = yaml.NewDecoder(f).Decode(sourceApiKeysMap)
+	sourceApiKeysMap, decodeErr := decodeProviderKeys(configs)
+	if decodeErr != nil {
+		return decodeErr
+	}
 	for _, source := range passive.AllSources {
 		sourceName := strings.ToLower(source.Name())
 		apiKeys := sourceApiKeysMap[sourceName]
@@ -60,3 +71,85 @@ func UnmarshalFrom(file string) error {
 	}
 	return err
 }
+
+// UnmarshalKeysFrom reads the api keys of each source of the provider config
+func UnmarshalKeysFrom(file string) (map[string][]string, error) {
+	configs, err := readProviderConfig(file)
+	if isFatalErr(err) {
+		return nil, err
+	}
+	return decodeProviderKeys(configs)
+}
+
+// decodeProviderKeys decodes the api keys of the provider config entries
+func decodeProviderKeys(configs map[string]yaml.Node) (map[string][]string, error) {
+	sourceApiKeysMap := map[string][]string{}
+	for name, node := range configs {
+		if name == rateLimitsKey || name == cacheTTLKey {
+			continue
+		}
+		var apiKeys []string
+		if err := node.Decode(&apiKeys); err != nil {
+			return nil, err
+		}
+		sourceApiKeysMap[strings.ToLower(name)] = apiKeys
+	}
+	return sourceApiKeysMap, nil
+}
+
+// UnmarshalRateLimitsFrom reads the source rate limits of the provider config
+func UnmarshalRateLimitsFrom(file string) (map[string]subscraping.RateLimit, error) {
+	configs, err := readProviderConfig(file)
+	if isFatalErr(err) {
+		return nil, err
+	}
+
+	configured := map[string]subscraping.RateLimit{}
+	if node, ok := configs[rateLimitsKey]; ok {
+		if err := node.Decode(configured); err != nil {
+			return nil, err
+		}
+	}
+	rateLimits := make(map[string]subscraping.RateLimit, len(configured))
+	for name, limit := range configured {
+		rateLimits[strings.ToLower(name)] = limit
+	}
+	return rateLimits, nil
+}
+
+// UnmarshalCacheTTLsFrom reads the source cache ttls of the provider config
+func UnmarshalCacheTTLsFrom(file string) (map[string]time.Duration, error) {
+	configs, err := readProviderConfig(file)
+	if isFatalErr(err) {
+		return nil, err
+	}
+
+	configured := map[string]string{}
+	if node, ok := configs[cacheTTLKey]; ok {
+		if err := node.Decode(configured); err != nil {
+			return nil, err
+		}
+	}
+	ttls := make(map[string]time.Duration, len(configured))
+	for name, value := range configured {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache ttl %q of %s: %s", value, name, err)
+		}
+		ttls[strings.ToLower(name)] = ttl
+	}
+	return ttls, nil
+}
+
+// readProviderConfig decodes the entries of the provider config
+func readProviderConfig(file string) (map[string]yaml.Node, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	configs := map[string]yaml.Node{}
+	err = yaml.NewDecoder(f).Decode(configs)
+	return configs, err
+}
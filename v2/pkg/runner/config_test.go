@@ -2,8 +2,13 @@ package runner
 
 import (
 	"github.com/stretchr/testify/require"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
 func TestConfigGetDirectory(t *testing.T) {
@@ -19,3 +24,61 @@ func TestConfigGetDirectory(t *testing.T) {
 
 	require.Equal(t, directory, config, "Directory and config should be equal")
 }
+
+func TestUnmarshalRateLimitsFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "provider-config.yaml")
+	err := os.WriteFile(file, []byte(`virustotal: []
+rate-limits:
+  VirusTotal:
+    per-second: 0.066
+    per-day: 500
+  urlscan:
+    max-in-flight: 1
+`), 0644)
+	require.Nil(t, err)
+
+	require.Nil(t, UnmarshalFrom(file))
+	rateLimits, err := UnmarshalRateLimitsFrom(file)
+	require.Nil(t, err)
+	require.Equal(t, map[string]subscraping.RateLimit{
+		"virustotal": {PerSecond: 0.066, PerDay: 500},
+		"urlscan":    {MaxInFlight: 1},
+	}, rateLimits)
+}
+
+func TestUnmarshalCacheTTLsFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "provider-config.yaml")
+	err := os.WriteFile(file, []byte(`virustotal: []
+cache-ttl:
+  AlienVault: 12h
+  sitemap: 0s
+`), 0644)
+	require.Nil(t, err)
+
+	require.Nil(t, UnmarshalFrom(file))
+	ttls, err := UnmarshalCacheTTLsFrom(file)
+	require.Nil(t, err)
+	require.Equal(t, map[string]time.Duration{"alienvault": 12 * time.Hour, "sitemap": 0}, ttls)
+
+	err = os.WriteFile(file, []byte("cache-ttl:\n  alienvault: soon\n"), 0644)
+	require.Nil(t, err)
+	_, err = UnmarshalCacheTTLsFrom(file)
+	require.NotNil(t, err)
+}
+
+func TestSaveKeyState(t *testing.T) {
+	dir := t.TempDir()
+	r := &Runner{options: &Options{ProviderConfig: filepath.Join(dir, "provider-config.yaml")}, keys: subscraping.NewKeyManager()}
+	file := filepath.Join(dir, keyStateFileName)
+
+	r.saveKeyState()
+	_, err := os.Stat(file)
+	require.True(t, os.IsNotExist(err), "a run without keys writes no key state")
+
+	_, _ = r.keys.Do("virustotal", []string{"key-1"}, func(key string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3600"}}, Body: http.NoBody}, nil
+	})
+	r.saveKeyState()
+	_, err = os.Stat(file)
+	require.Nil(t, err)
+}
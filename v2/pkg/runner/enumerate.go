@@ -11,6 +11,8 @@ import (
 
 	"github.com/projectdiscovery/gologger"
 
+	"github.com/chainreactors/urlfounder/v2/pkg/collapse"
+	"github.com/chainreactors/urlfounder/v2/pkg/inventory"
 	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
@@ -30,7 +32,7 @@ func (r *Runner) EnumerateSingleURLWithCtx(ctx context.Context, domain string, w
 	//If yes, create the resolution pool and get the wildcards for the current domain
 	var resolutionPool *resolve.ResolutionPool
 	if r.options.RemoveWildcard {
-		resolutionPool = r.resolverClient.NewResolutionPool(r.options.Threads, r.options.RemoveWildcard)
+		resolutionPool = r.resolverClient.NewResolutionPool(ctx, r.options.Threads, r.options.RemoveWildcard)
 		err := resolutionPool.InitWildcards(domain)
 		if err != nil {
 			// Log the error but don't quit.
@@ -38,56 +40,194 @@ func (r *Runner) EnumerateSingleURLWithCtx(ctx context.Context, domain string, w
 		}
 	}
 
+	// A resumed enumeration continues from the progress of the previous run
+	resuming := false
+	if r.checkpoint != nil {
+		progress := r.checkpoint.progress(domain)
+		resuming = len(progress.Cursors) > 0 || len(progress.Done) > 0
+		ctx = subscraping.WithProgress(ctx, progress)
+	}
+
+	// With -diff the urls found by the previous runs are not written
+	var snapshot *inventory.Snapshot
+	found := r.newInventoryRun()
+	if r.inventory != nil {
+		var err error
+		if snapshot, err = r.inventory.Snapshot(domain); err != nil {
+			gologger.Warning().Msgf("Could not read the inventory of %s: %s\n", domain, err)
+		}
+	}
+
 	// Run the passive url enumeration
 	now := time.Now()
 	passiveResults := r.passiveAgent.EnumerateURLsWithCtx(ctx, domain, r.options.Proxy, r.options.RateLimit, r.options.Timeout, time.Duration(r.options.MaxEnumerationTime)*time.Minute)
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
+	// Urls outside of the scope are dropped before deduplication
+	domainScope := r.scope.ForDomain(domain)
+	droppedMap := make(map[string]int)
+	// Track the urls seen outside of the date range per source
+	outdatedMap := make(map[string]int)
 	// Create a unique map for filtering duplicate urls out 过滤重复子域
 	uniqueMap := make(map[string]resolve.HostEntry)
 	// Create a map to track sources for each host 跟踪host源
 	sourceMap := make(map[string]map[string]struct{})
-	// Process the results in a separate goroutine
-	go func() {
-		for result := range passiveResults {
-			switch result.Type {
-			case subscraping.Error:
-				gologger.Warning().Msgf("Could not run source %s: %s\n", result.Source, result.Error)
-			case subscraping.URL:
-				// 验证找到的子域并删除通配符
-				url := strings.ReplaceAll(strings.ToLower(result.Value), "*.", "")
+	// Track the canonical urls folded into each group when collapsing
+	collapsed := make(map[string]struct{})
+	// The urls found by the previous run are known already, when
+	// streaming they were written and are not written again
+	resumed := r.checkpoint.urls(domain)
+	for url, state := range resumed {
+		key, normalizedKey, ok := r.dedupKey(url)
+		if !ok {
+			continue
+		}
+		uniqueMap[key] = resolve.HostEntry{Host: url, Source: state.Source, Count: state.Count, Metadata: state.Metadata}
+		sourceMap[url] = make(map[string]struct{}, len(state.Sources))
+		for _, source := range state.Sources {
+			sourceMap[url][source] = struct{}{}
+		}
+		collapsed[normalizedKey] = struct{}{}
+		for _, source := range state.Sources {
+			found.add(url, source)
+		}
+	}
+	// The urls found by the previous run before the filters are not known,
+	// the ones missing from this run are not reported removed
+	if resuming || len(resumed) > 0 {
+		found.setPartial()
+	}
+	if len(resumed) > 0 {
+		gologger.Info().Msgf("Resuming %s with %d urls found by the previous run\n", domain, len(resumed))
+	}
+	outputWriter := NewOutputWriter(r.options.JSON)
+	// streamErr keeps the first error met while streaming results
+	var streamErr error
+	stream := func(hosts map[string]resolve.HostEntry, sources map[string]map[string]struct{}, results map[string]resolve.Result) {
+		if r.options.Diff {
+			hosts, sources, results = r.newResults(snapshot, hosts, sources, results)
+		}
+		if err := r.writeResults(outputWriter, domain, hosts, sources, results, writers); err != nil && streamErr == nil {
+			gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
+			streamErr = err
+		}
+	}
+	processURL := func(result subscraping.Result) {
+		// 验证找到的子域并删除通配符
+		url := strings.ReplaceAll(result.Value, "*.", "")
+		// The inventory records the urls whatever the filters of the run
+		found.add(url, result.Source)
+		if !domainScope.InScope(url) {
+			droppedMap[result.Source]++
+			return
+		}
+		// Sources not querying a date range are filtered on the dates they report
+		if !r.options.dates.Contains(result.Metadata) {
+			outdatedMap[result.Source]++
+			return
+		}
 
-				if matchURL := r.filterAndMatchURL(url); matchURL {
-					if _, ok := uniqueMap[url]; !ok {
-						sourceMap[url] = make(map[string]struct{})
-					}
+		if matchURL := r.filterAndMatchURL(strings.ToLower(url)) && r.filterAndMatchRegex(url) && r.filterExtension(url); matchURL {
+			key, normalizedKey, ok := r.dedupKey(url)
+			if !ok {
+				return
+			}
+			if hostEntry, ok := uniqueMap[key]; ok {
+				url = hostEntry.Host
+				if _, ok := collapsed[normalizedKey]; r.options.Collapse && !ok {
+					collapsed[normalizedKey] = struct{}{}
+					hostEntry.Count++
+				}
+				hostEntry.Metadata = mergeMetadata(hostEntry.Metadata, result.Metadata)
+				uniqueMap[key] = hostEntry
+			} else {
+				sourceMap[url] = make(map[string]struct{})
+			}
 
-					// Log the verbose message about the found url per source
-					if _, ok := sourceMap[url][result.Source]; !ok {
-						gologger.Verbose().Label(result.Source).Msg(url)
-					}
+			// Log the verbose message about the found url per source
+			_, knownSource := sourceMap[url][result.Source]
+			if !knownSource {
+				gologger.Verbose().Label(result.Source).Msg(url)
+			}
 
-					sourceMap[url][result.Source] = struct{}{}
+			sourceMap[url][result.Source] = struct{}{}
 
-					// Check if the url is a duplicate. If not,
-					// send the url for resolution.
-					if _, ok := uniqueMap[url]; ok {
-						continue
-					}
+			// Check if the url is a duplicate. If not,
+			// send the url for resolution.
+			if hostEntry, ok := uniqueMap[key]; ok {
+				// When streaming with sources, every new source of a known
+				// url is written as an additional line
+				if r.options.Stream && r.options.CaptureSources && !r.options.RemoveWildcard && !knownSource {
+					stream(map[string]resolve.HostEntry{url: hostEntry}, map[string]map[string]struct{}{url: {result.Source: {}}}, nil)
+				}
+				r.checkpoint.recordURL(domain, hostEntry, sourceMap[url])
+				return
+			}
 
-					hostEntry := resolve.HostEntry{Host: url, Source: result.Source}
+			hostEntry := resolve.HostEntry{Host: url, Source: result.Source, Metadata: mergeMetadata(nil, result.Metadata)}
+			if r.options.Collapse {
+				collapsed[normalizedKey] = struct{}{}
+				hostEntry.Count = 1
+			}
 
-					uniqueMap[url] = hostEntry
-					// If the user asked to remove wildcard then send on the resolve
-					// queue. Otherwise, if mode is not verbose print the results on
-					// the screen as they are discovered.
-					if r.options.RemoveWildcard {
-						resolutionPool.Tasks <- hostEntry
-					}
+			uniqueMap[key] = hostEntry
+			r.checkpoint.recordURL(domain, hostEntry, sourceMap[url])
+			// If the user asked to remove wildcard then send on the resolve
+			// queue. Otherwise, if streaming print the results on
+			// the screen as they are discovered.
+			if r.options.RemoveWildcard {
+				resolutionPool.Tasks <- hostEntry
+			} else if r.options.Stream {
+				stream(map[string]resolve.HostEntry{url: hostEntry}, map[string]map[string]struct{}{url: {result.Source: {}}}, nil)
+			}
+		}
+	}
+	// Process the results in a separate goroutine
+	go func() {
+		// The urls found by the previous run are resolved again
+		if r.options.RemoveWildcard {
+			for url := range resumed {
+				if key, _, ok := r.dedupKey(url); ok {
+					resolutionPool.Tasks <- uniqueMap[key]
 				}
 			}
 		}
+		for result := range passiveResults {
+			switch result.Type {
+			case subscraping.Error:
+				gologger.Warning().Msgf("Could not run source %s: %s\n", result.Source, result.Error)
+				found.setPartial()
+			case subscraping.URL:
+				processURL(result)
+			case subscraping.Cursor:
+				r.checkpoint.recordCursor(domain, result.Source, result.Value)
+			case subscraping.Done:
+				r.checkpoint.recordSourceDone(domain, result.Source)
+			}
+		}
+		// The sources stopped by the time limit missed some urls
+		maxTime := time.Duration(r.options.MaxEnumerationTime) * time.Minute
+		if maxTime > 0 && time.Since(now) >= maxTime {
+			found.setPartial()
+		}
+		// Feed the endpoints referenced by the discovered scripts back
+		// into the results once every source has finished, the scripts
+		// are read within the time limit of the sources
+		if r.options.ExtractJS {
+			jsCtx := ctx
+			if maxTime > 0 {
+				var cancel context.CancelFunc
+				jsCtx, cancel = context.WithDeadline(ctx, now.Add(maxTime))
+				defer cancel()
+			}
+			for _, result := range r.extractJSEndpoints(jsCtx, domain, domainScope, uniqueMap) {
+				processURL(result)
+			}
+			if jsCtx.Err() != nil {
+				found.setPartial()
+			}
+		}
 		// Close the task channel only if wildcards are asked to be removed
 		if r.options.RemoveWildcard {
 			close(resolutionPool.Tasks)
@@ -108,32 +248,32 @@ func (r *Runner) EnumerateSingleURLWithCtx(ctx context.Context, domain string, w
 				// Add the found url to a map.
 				if _, ok := foundResults[result.Host]; !ok {
 					foundResults[result.Host] = result
+					if r.options.Stream {
+						stream(nil, nil, map[string]resolve.Result{result.Host: result})
+					}
 				}
 			}
 		}
 	}
 
 	wg.Wait()
-	outputWriter := NewOutputWriter(r.options.JSON)
-	// Now output all results in output writers
-	var err error
-	for _, writer := range writers {
-		if r.options.StatusCode && r.options.Title {
-			err = outputWriter.WriteStatusCodeAndTitle(domain, foundResults, writer)
-		} else if r.options.StatusCode {
-			err = outputWriter.WriteStatusCode(domain, foundResults, writer)
-		} else {
-			if r.options.RemoveWildcard {
-				err = outputWriter.WriteHostNoWildcard(domain, foundResults, writer)
-			} else {
-				if r.options.CaptureSources {
-					err = outputWriter.WriteSourceHost(domain, sourceMap, writer)
-				} else {
-					err = outputWriter.WriteHost(domain, uniqueMap, writer)
-				}
-			}
+	if r.options.Stream && streamErr != nil {
+		return streamErr
+	}
+	// The results of an interrupted enumeration are written once it is
+	// resumed, along with the ones of the sources it was waiting for
+	if r.checkpoint != nil && ctx.Err() != nil {
+		gologger.Info().Msgf("Enumeration of %s interrupted with %d urls found, its progress is kept in %s\n", domain, len(uniqueMap), r.options.Resume)
+		return nil
+	}
+	r.filterMimes(uniqueMap, foundResults)
+	if !r.options.Stream {
+		hosts, sources, results := uniqueMap, sourceMap, foundResults
+		if r.options.Diff {
+			hosts, sources, results = r.newResults(snapshot, hosts, sources, results)
 		}
-		if err != nil {
+		// Now output all results in output writers
+		if err := r.writeResults(outputWriter, domain, hosts, sources, results, writers); err != nil {
 			gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
 			return err
 		}
@@ -154,15 +294,112 @@ func (r *Runner) EnumerateSingleURLWithCtx(ctx context.Context, domain string, w
 		}
 	}
 	gologger.Info().Msgf("Found %d urls for %s in %s\n", numberOfURLs, domain, duration)
+	printDropped(domain, "out-of-scope", droppedMap)
+	printDropped(domain, "out-of-range", outdatedMap)
+	if ctx.Err() != nil {
+		found.setPartial()
+	}
+	if err := r.updateInventory(outputWriter, domain, domainScope, found, writers); err != nil {
+		gologger.Error().Msgf("Could not write removed urls for %s: %s\n", domain, err)
+		return err
+	}
+	r.checkpoint.complete(domain)
 
 	if r.options.Statistics {
 		gologger.Info().Msgf("Printing source statistics for %s", domain)
-		printStatistics(r.passiveAgent.GetStatistics())
+		printStatistics(r.passiveAgent.GetStatistics(domain))
+	}
+
+	return nil
+}
+
+// dedupKey returns the key the url is deduplicated on and its canonical form.
+// Duplicates are detected on the canonical form of the url, the output keeps
+// the url as it was first found.
+func (r *Runner) dedupKey(url string) (key, normalizedKey string, ok bool) {
+	normalizedKey = r.normalizer.Key(url)
+	if !r.options.Collapse {
+		return normalizedKey, normalizedKey, true
 	}
+	// When collapsing, urls sharing a pattern are duplicates of
+	// the first one found and noise is dropped altogether
+	pattern, ok := collapse.Pattern(url)
+	return pattern, normalizedKey, ok
+}
+
+// writeResults writes the results in the format selected by the options to every writer
+func (r *Runner) writeResults(outputWriter *OutputWriter, domain string, uniqueMap map[string]resolve.HostEntry, sourceMap map[string]map[string]struct{}, foundResults map[string]resolve.Result, writers []io.Writer) error {
+	// Domains enumerated concurrently share the writers, hold the lock
+	// for the whole batch so that their lines never interleave
+	r.outputMutex.Lock()
+	defer r.outputMutex.Unlock()
 
+	if r.options.Collapse {
+		foundResults = withCounts(foundResults, uniqueMap)
+	}
+	var err error
+	for _, writer := range writers {
+		if r.options.StatusCode && r.options.Title {
+			err = outputWriter.WriteStatusCodeAndTitle(domain, foundResults, writer)
+		} else if r.options.StatusCode {
+			err = outputWriter.WriteStatusCode(domain, foundResults, writer)
+		} else {
+			if r.options.RemoveWildcard {
+				err = outputWriter.WriteHostNoWildcard(domain, foundResults, writer)
+			} else {
+				if r.options.CaptureSources {
+					err = outputWriter.WriteSourceHost(domain, sourceMap, hostsByURL(uniqueMap), writer)
+				} else {
+					err = outputWriter.WriteHost(domain, uniqueMap, writer)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// mergeMetadata returns a copy of the metadata merged with the one found
+// by another source. The entries already handed to the resolvers or the
+// writers keep their metadata untouched.
+func mergeMetadata(metadata, found *subscraping.Metadata) *subscraping.Metadata {
+	if found == nil {
+		return metadata
+	}
+	merged := &subscraping.Metadata{}
+	merged.Merge(metadata)
+	merged.Merge(found)
+	return merged
+}
+
+// hostsByURL indexes the host entries by url
+func hostsByURL(hosts map[string]resolve.HostEntry) map[string]resolve.HostEntry {
+	byURL := make(map[string]resolve.HostEntry, len(hosts))
+	for _, entry := range hosts {
+		byURL[entry.Host] = entry
+	}
+	return byURL
+}
+
+// withCounts returns the resolved urls with the count of their group, the
+// urls collapsed into a group after it was resolved are counted as well
+func withCounts(results map[string]resolve.Result, hosts map[string]resolve.HostEntry) map[string]resolve.Result {
+	if len(hosts) == 0 {
+		return results
+	}
+	byURL := hostsByURL(hosts)
+	counted := make(map[string]resolve.Result, len(results))
+	for host, result := range results {
+		if entry, ok := byURL[result.Host]; ok {
+			result.Count = entry.Count
+		}
+		counted[host] = result
+	}
+	return counted
+}
+
 func (r *Runner) filterAndMatchURL(url string) bool {
 	if r.options.filterRegexes != nil {
 		for _, filter := range r.options.filterRegexes {
@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/collapse"
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+)
+
+// extensionPresets are the named groups of extensions accepted by the extension filters
+var extensionPresets = map[string][]string{
+	"static": collapse.StaticExtensions,
+	"docs":   {"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "odt", "ods", "rtf", "txt", "csv", "md"},
+	"backup": {"bak", "backup", "old", "orig", "save", "swp", "tmp", "zip", "tar", "gz", "tgz", "rar", "7z", "sql", "dump"},
+	"js":     {"js", "mjs", "jsx", "ts", "map"},
+	"config": {"conf", "config", "cfg", "ini", "env", "yml", "yaml", "toml", "xml", "json", "properties"},
+}
+
+// mimePresets are the named groups of mime types accepted by the mime filters,
+// a trailing * matches any subtype
+var mimePresets = map[string][]string{
+	"static": {"image/*", "font/*", "audio/*", "video/*", "text/css", "application/font-woff", "application/vnd.ms-fontobject"},
+	"docs":   {"application/pdf", "application/msword", "application/vnd.openxmlformats-officedocument.*", "application/vnd.ms-*", "application/rtf", "text/plain", "text/csv", "text/markdown"},
+	"backup": {"application/zip", "application/gzip", "application/x-gzip", "application/x-tar", "application/x-rar-compressed", "application/x-7z-compressed", "application/sql", "application/octet-stream"},
+	"js":     {"application/javascript", "application/x-javascript", "text/javascript", "application/json+sourcemap"},
+	"config": {"application/json", "application/xml", "text/xml", "application/x-yaml", "text/yaml", "application/toml"},
+}
+
+// expandExtensions returns the set of extensions, without leading dot, of the
+// given extensions and preset names
+func expandExtensions(values []string) map[string]struct{} {
+	extensions := make(map[string]struct{})
+	for _, value := range values {
+		value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), ".")
+		if value == "" {
+			continue
+		}
+		if preset, ok := extensionPresets[value]; ok {
+			for _, extension := range preset {
+				extensions[extension] = struct{}{}
+			}
+			continue
+		}
+		extensions[value] = struct{}{}
+	}
+	return extensions
+}
+
+// expandMimes returns the mime types of the given types and preset names
+func expandMimes(values []string) ([]string, error) {
+	var mimes []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if preset, ok := mimePresets[value]; ok {
+			mimes = append(mimes, preset...)
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			return nil, fmt.Errorf("invalid mime type %q, expected type/subtype or one of the presets static, docs, backup, js, config", value)
+		}
+		mimes = append(mimes, value)
+	}
+	return mimes, nil
+}
+
+// urlExtension returns the lowercased extension of the url path without leading dot
+func urlExtension(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(path.Ext(parsed.Path)), ".")
+}
+
+// matchMime returns true if the mime type matches any of the patterns
+func matchMime(mime string, patterns []string) bool {
+	mime, _, _ = strings.Cut(strings.ToLower(mime), ";")
+	mime = strings.TrimSpace(mime)
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(mime, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if mime == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// filterExtension returns true if the url passes the extension filters
+func (r *Runner) filterExtension(url string) bool {
+	if len(r.options.excludeExtensions) > 0 || len(r.options.extensions) > 0 {
+		extension := urlExtension(url)
+		if _, ok := r.options.excludeExtensions[extension]; ok {
+			return false
+		}
+		if _, ok := r.options.extensions[extension]; len(r.options.extensions) > 0 && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMime returns true if the mime type passes the mime type filters,
+// the urls whose mime type no source reported are kept
+func (r *Runner) filterMime(mime string) bool {
+	if mime == "" {
+		return true
+	}
+	if matchMime(mime, r.options.excludeMimes) {
+		return false
+	}
+	if len(r.options.mimes) > 0 && !matchMime(mime, r.options.mimes) {
+		return false
+	}
+	return true
+}
+
+// regexComponents are the parts of an url a raw regex can be restricted to
+// with a component: prefix, param matching the names of the query parameters
+var regexComponents = []string{"url", "host", "path", "query", "param"}
+
+// componentRegex is a raw regex applied to a component of the url
+type componentRegex struct {
+	component string
+	regex     *regexp.Regexp
+}
+
+// compileComponentRegex compiles a regex optionally prefixed by the url
+// component it targets, e.g. path:^/api/v[0-9]+/
+func compileComponentRegex(value string) (*componentRegex, error) {
+	component := "url"
+	if prefix, expression, ok := strings.Cut(value, ":"); ok {
+		for _, name := range regexComponents {
+			if prefix == name {
+				component, value = prefix, expression
+				break
+			}
+		}
+	}
+	regex, err := regexp.Compile(value)
+	if err != nil {
+		return nil, err
+	}
+	return &componentRegex{component: component, regex: regex}, nil
+}
+
+func (c *componentRegex) match(rawURL string) bool {
+	if c.component == "url" {
+		return c.regex.MatchString(rawURL)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch c.component {
+	case "host":
+		return c.regex.MatchString(parsed.Hostname())
+	case "path":
+		return c.regex.MatchString(parsed.EscapedPath())
+	case "query":
+		return c.regex.MatchString(parsed.RawQuery)
+	case "param":
+		for name := range parsed.Query() {
+			if c.regex.MatchString(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileComponentRegexes compiles the raw regexes of the named option
+func compileComponentRegexes(option string, values []string) ([]*componentRegex, error) {
+	regexes := make([]*componentRegex, 0, len(values))
+	for _, value := range values {
+		regex, err := compileComponentRegex(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s option: %s", value, option, err)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// filterAndMatchRegex returns true if the url passes the raw regex filters
+func (r *Runner) filterAndMatchRegex(url string) bool {
+	for _, filter := range r.options.rawFilterRegexes {
+		if filter.match(url) {
+			return false
+		}
+	}
+	if len(r.options.rawMatchRegexes) == 0 {
+		return true
+	}
+	for _, match := range r.options.rawMatchRegexes {
+		if match.match(url) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterMimes removes the urls whose mime type, merged across the sources
+// once they are done, does not pass the mime type filters
+func (r *Runner) filterMimes(hosts map[string]resolve.HostEntry, results map[string]resolve.Result) {
+	if len(r.options.mimes) == 0 && len(r.options.excludeMimes) == 0 {
+		return
+	}
+	for key, entry := range hosts {
+		if entry.Metadata == nil || r.filterMime(entry.Metadata.Mime) {
+			continue
+		}
+		delete(hosts, key)
+		delete(results, entry.Host)
+	}
+}
@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/passive"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestFilterExtensionAndMime(t *testing.T) {
+	newRunner := func(options *Options) *Runner {
+		options.Domain = []string{"example.com"}
+		options.Threads = 10
+		options.Timeout = 10
+		options.Output = os.Stdout
+		err := options.validateOptions()
+		require.Nil(t, err)
+		runner, err := NewRunner(options)
+		require.Nil(t, err)
+		return runner
+	}
+
+	t.Run("Extensions", func(t *testing.T) {
+		runner := newRunner(&Options{Extensions: []string{"php", ".ASPX", "config"}})
+		require.True(t, runner.filterExtension("https://example.com/index.php?id=1"))
+		require.True(t, runner.filterExtension("https://example.com/Default.aspx"))
+		require.True(t, runner.filterExtension("https://example.com/.env"))
+		require.False(t, runner.filterExtension("https://example.com/app.js"))
+		require.False(t, runner.filterExtension("https://example.com/login"))
+	})
+
+	t.Run("Exclude Extensions", func(t *testing.T) {
+		runner := newRunner(&Options{ExcludeExtensions: []string{"static"}})
+		require.False(t, runner.filterExtension("https://example.com/logo.PNG"))
+		require.True(t, runner.filterExtension("https://example.com/login"))
+	})
+
+	t.Run("Mime", func(t *testing.T) {
+		runner := newRunner(&Options{Mime: []string{"text/html", "js"}, ExcludeMime: []string{"image/*"}})
+		require.True(t, runner.filterMime("text/html; charset=utf-8"))
+		require.True(t, runner.filterMime("application/javascript"))
+		require.False(t, runner.filterMime("application/json"))
+		require.False(t, runner.filterMime("image/png"))
+		// results without a reported mime type are not filtered on it
+		require.True(t, runner.filterMime(""))
+	})
+
+	t.Run("Invalid Mime", func(t *testing.T) {
+		options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, Mime: []string{"html"}}
+		require.ErrorContains(t, options.validateOptions(), `"html"`)
+	})
+}
+
+func TestFilterAndMatchRegex(t *testing.T) {
+	newRunner := func(match, filter []string) *Runner {
+		options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, Output: os.Stdout, MatchRegex: match, FilterRegex: filter}
+		require.Nil(t, options.validateOptions())
+		runner, err := NewRunner(options)
+		require.Nil(t, err)
+		return runner
+	}
+
+	t.Run("Whole URL", func(t *testing.T) {
+		runner := newRunner([]string{`/api/v[0-9]{1,2}/`}, nil)
+		require.True(t, runner.filterAndMatchRegex("https://example.com/api/v2/users"))
+		require.False(t, runner.filterAndMatchRegex("https://example.com/API/v2/users"))
+	})
+
+	t.Run("Components", func(t *testing.T) {
+		runner := newRunner([]string{`host:^(dev|staging)\.`, `param:^(redirect|next)$`}, []string{`path:\.(png|css)$`, `query:debug=1`})
+		require.True(t, runner.filterAndMatchRegex("https://dev.example.com/"))
+		require.True(t, runner.filterAndMatchRegex("https://example.com/login?next=/home"))
+		require.False(t, runner.filterAndMatchRegex("https://example.com/login?q=next"))
+		require.False(t, runner.filterAndMatchRegex("https://dev.example.com/logo.png"))
+		require.False(t, runner.filterAndMatchRegex("https://dev.example.com/?debug=1"))
+	})
+
+	t.Run("Invalid Regex", func(t *testing.T) {
+		options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, FilterRegex: []string{"path:(unclosed"}}
+		require.ErrorContains(t, options.validateOptions(), `"path:(unclosed"`)
+	})
+}
+
+func TestStripRegexString(t *testing.T) {
+	require.Equal(t, `^.*\.example\.com$`, stripRegexString("*.example.com"))
+	// The -m and -f patterns keep the regex syntax besides the dots and stars
+	require.Equal(t, `^.*/api/(v1|v2)/.*$`, stripRegexString("*/api/(v1|v2)/*"))
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		end   bool
+		want  time.Time
+	}{
+		{"2018", false, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2018", true, time.Date(2018, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"2018-02", true, time.Date(2018, 2, 28, 23, 59, 59, 0, time.UTC)},
+		{"2018-02-03", false, time.Date(2018, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{"2018-02-03T04:05:06Z", true, time.Date(2018, 2, 3, 4, 5, 6, 0, time.UTC)},
+		{"90d", false, time.Date(2023, 12, 16, 12, 0, 0, 0, time.UTC)},
+		{"2w", false, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"1y", true, time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.value, now, tt.end)
+		require.Nil(t, err, tt.value)
+		require.Equal(t, tt.want, got, tt.value)
+	}
+
+	for _, value := range []string{"", "yesterday", "d", "-3d", "2018-13"} {
+		_, err := parseDate(value, now, false)
+		require.NotNil(t, err, value)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	options := &Options{Since: "2020", Until: "2019"}
+	require.NotNil(t, options.parseDateRange(time.Now()))
+
+	options = &Options{Since: "2019", Until: "2019"}
+	require.Nil(t, options.parseDateRange(time.Now()))
+	require.Equal(t, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), options.dates.Since)
+	require.Equal(t, time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC), options.dates.Until)
+}
+
+// orderedSource returns the urls with the mime type it reports, after
+// the source it waits for returned its urls
+type orderedSource struct {
+	name string
+	mime string
+	urls []string
+	// after is closed by the source returning its urls first
+	after chan struct{}
+	done  chan struct{}
+}
+
+func (s *orderedSource) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	go func() {
+		defer close(results)
+		defer close(s.done)
+		if s.after != nil {
+			<-s.after
+		}
+		for _, url := range s.urls {
+			result := subscraping.Result{Source: s.name, Type: subscraping.URL, Value: url}
+			if s.mime != "" {
+				result.Metadata = &subscraping.Metadata{Mime: s.mime}
+			}
+			results <- result
+		}
+	}()
+	return results
+}
+
+func (s *orderedSource) Name() string                       { return s.name }
+func (s *orderedSource) IsDefault() bool                    { return false }
+func (s *orderedSource) HasRecursiveSupport() bool          { return false }
+func (s *orderedSource) NeedsKey() bool                     { return false }
+func (s *orderedSource) AddApiKeys(_ []string)              {}
+func (s *orderedSource) Statistics() subscraping.Statistics { return subscraping.Statistics{} }
+
+func TestFilterMimeAcrossSources(t *testing.T) {
+	urls := []string{"https://example.com/logo", "https://example.com/login"}
+	enumerate := func(imageFirst bool) string {
+		image := &orderedSource{name: "image", mime: "image/png", urls: urls[:1], done: make(chan struct{})}
+		unknown := &orderedSource{name: "unknown", urls: urls, done: make(chan struct{})}
+		if imageFirst {
+			unknown.after = image.done
+		} else {
+			image.after = unknown.done
+		}
+		passive.NameSourceMap[image.name], passive.NameSourceMap[unknown.name] = image, unknown
+		defer delete(passive.NameSourceMap, image.name)
+		defer delete(passive.NameSourceMap, unknown.name)
+
+		options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, Output: os.Stdout,
+			Sources: []string{"image", "unknown"}, ExcludeMime: []string{"image/*"}}
+		require.Nil(t, options.validateOptions())
+		runner, err := NewRunner(options)
+		require.Nil(t, err)
+		var buf bytes.Buffer
+		require.Nil(t, runner.EnumerateSingleURL("example.com", []io.Writer{&buf}))
+		return buf.String()
+	}
+
+	// The url is dropped whichever source returns it first
+	require.Equal(t, "https://example.com/login\n", enumerate(true))
+	require.Equal(t, "https://example.com/login\n", enumerate(false))
+}
+
+func TestFilterMimeStream(t *testing.T) {
+	options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, Stream: true, Mime: []string{"text/html"}}
+	require.ErrorContains(t, options.validateOptions(), "-stream")
+}
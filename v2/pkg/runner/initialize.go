@@ -1,17 +1,161 @@
 package runner
 
 import (
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/chainreactors/urlfounder/v2/pkg/inventory"
+	"github.com/chainreactors/urlfounder/v2/pkg/jsfinder"
+	"github.com/chainreactors/urlfounder/v2/pkg/normalize"
 	"github.com/chainreactors/urlfounder/v2/pkg/passive"
 	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/scope"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping/sources/webarchive"
 	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
 )
 
 // initializePassiveEngine creates the passive engine and loads sources etc
 func (r *Runner) initializePassiveEngine() {
 	r.passiveAgent = passive.New(r.options.Sources, r.options.ExcludeSources, r.options.All, r.options.OnlyRecursive)
+	r.passiveAgent.PageSize = r.options.PageSize
+	r.passiveAgent.MaxPages = r.options.MaxPages
+	r.passiveAgent.MaxIndexes = r.options.CommonCrawlIndexes
+	r.passiveAgent.YearFrom = r.options.yearFrom
+	r.passiveAgent.YearTo = r.options.yearTo
+	r.passiveAgent.Dates = r.options.dates
+	r.passiveAgent.RateLimits = r.options.sourceRateLimits
+
+	// The health of the api keys is shared by every domain and the
+	// cooldowns of the previous runs are honored
+	r.keys = subscraping.NewKeyManager()
+	if file := r.keyStateFile(); file != "" {
+		if err := r.keys.Load(file); err != nil && !os.IsNotExist(err) {
+			gologger.Warning().Msgf("Could not load api key state from %s: %s\n", file, err)
+		}
+	}
+	r.passiveAgent.Keys = r.keys
+
+	// The results of the sources are kept for the next runs
+	if !r.options.NoCache && r.options.CacheTTL > 0 {
+		if dir, err := GetConfigDirectory(); err != nil {
+			gologger.Warning().Msgf("Could not find the cache directory: %s\n", err)
+		} else {
+			cache := subscraping.NewCache(filepath.Join(dir, cacheDirectoryName))
+			cache.TTL = r.options.CacheTTL
+			cache.TTLs = r.options.sourceCacheTTLs
+			cache.Refresh = r.options.Refresh
+			// The expired results are never read again
+			if removed, err := cache.Prune(); err != nil {
+				gologger.Warning().Msgf("Could not prune the cache: %s\n", err)
+			} else if removed > 0 {
+				gologger.Verbose().Msgf("Removed %d expired files from the cache\n", removed)
+			}
+			r.passiveAgent.Cache = cache
+		}
+	}
+}
+
+// initializeJSFinder creates the finder extracting the endpoints of the javascript
+// files, its requests are rate limited across the domains being enumerated
+func (r *Runner) initializeJSFinder() error {
+	if !r.options.ExtractJS {
+		return nil
+	}
+	session, err := subscraping.NewSession("", r.options.Proxy, r.options.RateLimit, r.options.Timeout)
+	if err != nil {
+		return fmt.Errorf("could not create session for javascript extraction: %w", err)
+	}
+	// The scripts are fetched within the global rate limit of the sources
+	session.RateLimiter = r.passiveAgent.GlobalLimiter(r.options.RateLimit)
+	r.jsFinder = jsfinder.New(session)
+
+	// The archived scripts are fetched within the budget of the webarchive source
+	archiveSession := *session
+	archiveSession.RateLimiter = r.passiveAgent.SourceLimiter(&webarchive.Source{}, r.options.RateLimit)
+	r.jsFinder.ArchiveSession = &archiveSession
+	return nil
+}
+
+// cacheDirectoryName is the directory of the config directory keeping the source results
+const cacheDirectoryName = "cache"
+
+// keyStateFileName is the name of the file keeping the cooldowns of the api keys
+const keyStateFileName = "key-state.json"
+
+// keyStateFile returns the file keeping the cooldowns of the api keys,
+// next to the provider config
+func (r *Runner) keyStateFile() string {
+	if r.options.ProviderConfig == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(r.options.ProviderConfig), keyStateFileName)
+}
+
+// saveKeyState keeps the cooldowns of the api keys for the next runs,
+// no file is written by the runs using no key
+func (r *Runner) saveKeyState() {
+	file := r.keyStateFile()
+	if file == "" || !r.keys.HasState() {
+		return
+	}
+	if err := r.keys.Save(file); err != nil {
+		gologger.Warning().Msgf("Could not save api key state to %s: %s\n", file, err)
+	}
+}
+
+// initializeCheckpoint loads the checkpoint given with -resume, if any
+func (r *Runner) initializeCheckpoint() error {
+	if r.options.Resume == "" {
+		return nil
+	}
+	checkpoint, err := loadCheckpoint(r.options.Resume)
+	if err != nil {
+		return fmt.Errorf("could not load checkpoint %s: %s", r.options.Resume, err)
+	}
+	if len(checkpoint.Domains) > 0 {
+		gologger.Info().Msgf("Resuming the enumeration saved in %s\n", r.options.Resume)
+	}
+	r.checkpoint = checkpoint
+	return nil
+}
+
+// initializeInventory opens the inventory recording the urls found by the runs, if asked
+func (r *Runner) initializeInventory() {
+	if r.options.Inventory == "" {
+		return
+	}
+	r.inventory = inventory.New(r.options.Inventory)
+}
+
+// initializeNormalizer creates the normalizer computing the deduplication keys
+func (r *Runner) initializeNormalizer() {
+	r.normalizer = &normalize.Normalizer{KeepScheme: r.options.KeepScheme}
+	if r.options.StripTrailingSlash {
+		r.normalizer.TrailingSlash = normalize.StripTrailingSlash
+	}
+}
+
+// initializeScope creates the scope from the scope rules and scope file
+func (r *Runner) initializeScope() error {
+	include := append([]string{}, r.options.Scope...)
+	exclude := append([]string{}, r.options.OutOfScope...)
+	if r.options.ScopeFile != "" {
+		fileInclude, fileExclude, err := scope.LoadFile(r.options.ScopeFile)
+		if err != nil {
+			return fmt.Errorf("could not read scope file %s: %s", r.options.ScopeFile, err)
+		}
+		include = append(include, fileInclude...)
+		exclude = append(exclude, fileExclude...)
+	}
+
+	var err error
+	r.scope, err = scope.New(include, exclude)
+	return err
 }
 
 // initializeResolver creates the resolver used to resolve the found urls
@@ -32,6 +176,7 @@ func (r *Runner) initializeResolver() error {
 	}
 
 	r.resolverClient = resolve.New()
+	r.resolverClient.Prober = resolve.NewProber(r.options.Proxy, r.options.Timeout, r.options.RateLimit)
 	var err error
 	r.resolverClient.DNSClient, err = dnsx.New(dnsx.Options{BaseResolvers: resolvers, MaxRetries: 5})
 	if err != nil {
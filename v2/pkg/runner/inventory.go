@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"io"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/inventory"
+	"github.com/chainreactors/urlfounder/v2/pkg/normalize"
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/scope"
+)
+
+// inventoryNormalizer computes the keys of the inventory, which do not
+// depend on the normalization options of the run
+var inventoryNormalizer = &normalize.Normalizer{}
+
+// inventoryRun collects the urls found for a domain before any filter, to
+// record them in the inventory. A nil run collects nothing.
+type inventoryRun struct {
+	urls map[string]inventory.Found
+	// partial is true when some urls may have been missed, by a source
+	// error, the time limit or an interruption
+	partial bool
+}
+
+// newInventoryRun returns the run collecting the urls, nil without inventory
+func (r *Runner) newInventoryRun() *inventoryRun {
+	if r.inventory == nil {
+		return nil
+	}
+	return &inventoryRun{urls: make(map[string]inventory.Found)}
+}
+
+// add records the url found by the source
+func (f *inventoryRun) add(url, source string) {
+	if f == nil {
+		return
+	}
+	key := inventoryNormalizer.Key(url)
+	found, ok := f.urls[key]
+	if !ok {
+		found = inventory.Found{URL: url, Sources: make(map[string]struct{})}
+		f.urls[key] = found
+	}
+	found.Sources[source] = struct{}{}
+}
+
+// setPartial records that some urls may have been missed
+func (f *inventoryRun) setPartial() {
+	if f != nil {
+		f.partial = true
+	}
+}
+
+// updateInventory records the urls found for the domain in the inventory.
+// With -diff-removed, the urls found by the last run and not anymore are
+// written as well, if they pass the filters of this run.
+func (r *Runner) updateInventory(outputWriter *OutputWriter, domain string, domainScope *scope.Scope, found *inventoryRun, writers []io.Writer) error {
+	if found == nil {
+		return nil
+	}
+	removed, err := r.inventory.Update(domain, found.urls, !found.partial)
+	if err != nil {
+		gologger.Warning().Msgf("Could not update the inventory of %s: %s\n", domain, err)
+		return nil
+	}
+	if !r.options.Diff {
+		return nil
+	}
+	if found.partial {
+		gologger.Info().Msgf("Enumeration of %s was partial, no url is reported as not found anymore\n", domain)
+		return nil
+	}
+
+	var filtered []string
+	for _, url := range removed {
+		if r.matchFilters(domainScope, url) {
+			filtered = append(filtered, url)
+		}
+	}
+	gologger.Info().Msgf("%d urls found by the last run of %s are not found anymore\n", len(filtered), domain)
+	if !r.options.DiffRemoved || len(filtered) == 0 {
+		return nil
+	}
+
+	r.outputMutex.Lock()
+	defer r.outputMutex.Unlock()
+	for _, writer := range writers {
+		if err := outputWriter.WriteRemoved(domain, filtered, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchFilters returns true if the url passes the scope and filters of the
+// run, its content type being unknown
+func (r *Runner) matchFilters(domainScope *scope.Scope, url string) bool {
+	if !domainScope.InScope(url) {
+		return false
+	}
+	if !r.filterAndMatchURL(strings.ToLower(url)) || !r.filterAndMatchRegex(url) || !r.filterExtension(url) {
+		return false
+	}
+	_, _, ok := r.dedupKey(url)
+	return ok
+}
+
+// newResults returns the results holding only the urls the snapshot of
+// the inventory does not know, in any of their forms
+func (r *Runner) newResults(snapshot *inventory.Snapshot, hosts map[string]resolve.HostEntry, sources map[string]map[string]struct{}, results map[string]resolve.Result) (map[string]resolve.HostEntry, map[string]map[string]struct{}, map[string]resolve.Result) {
+	newHosts := make(map[string]resolve.HostEntry)
+	for key, entry := range hosts {
+		if !snapshot.Known(inventoryNormalizer.Key(entry.Host)) {
+			newHosts[key] = entry
+		}
+	}
+	newSources := make(map[string]map[string]struct{})
+	for url, names := range sources {
+		if !snapshot.Known(inventoryNormalizer.Key(url)) {
+			newSources[url] = names
+		}
+	}
+	newFound := make(map[string]resolve.Result)
+	for host, result := range results {
+		if !snapshot.Known(inventoryNormalizer.Key(host)) {
+			newFound[host] = result
+		}
+	}
+	return newHosts, newSources, newFound
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/inventory"
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/scope"
+)
+
+func TestInventoryDiff(t *testing.T) {
+	dir := t.TempDir()
+	newRunner := func(options *Options) *Runner {
+		options.Domain = []string{"example.com"}
+		options.Threads, options.Timeout = 10, 10
+		options.Diff, options.DiffRemoved, options.Inventory = true, true, dir
+		require.Nil(t, options.validateOptions())
+		r := &Runner{options: options, inventory: inventory.New(dir)}
+		r.initializeNormalizer()
+		return r
+	}
+	domainScope := (&scope.Scope{}).ForDomain("example.com")
+	update := func(r *Runner, partial bool, urls ...string) string {
+		found := r.newInventoryRun()
+		for _, url := range urls {
+			found.add(url, "webarchive")
+		}
+		if partial {
+			found.setPartial()
+		}
+		var buf bytes.Buffer
+		require.Nil(t, r.updateInventory(NewOutputWriter(false), "example.com", domainScope, found, []io.Writer{&buf}))
+		return buf.String()
+	}
+
+	require.Empty(t, update(newRunner(&Options{}), false, "http://example.com/a", "https://example.com/b", "https://example.com/c.js", "https://example.com/d.js"))
+
+	// The same url found in another form is not new, nor removed, whatever
+	// the options of the run. The removed urls pass the filters of the run.
+	second := newRunner(&Options{Collapse: true, KeepScheme: true, Extensions: []string{"js"}})
+	snapshot, err := second.inventory.Snapshot("example.com")
+	require.Nil(t, err)
+	hosts, _, _ := second.newResults(snapshot, map[string]resolve.HostEntry{
+		"a": {Host: "https://example.com/a"},
+		"e": {Host: "https://example.com/e.js"},
+	}, nil, nil)
+	require.Equal(t, map[string]resolve.HostEntry{"e": {Host: "https://example.com/e.js"}}, hosts)
+	require.Equal(t, "- https://example.com/d.js\n", update(second, false, "https://example.com/a", "https://example.com/c.js", "https://example.com/e.js"))
+
+	// A partial run reports nothing removed, the next complete run
+	// reports the urls missing since the last complete run
+	require.Empty(t, update(newRunner(&Options{}), true, "https://example.com/a"))
+	require.Equal(t, "- https://example.com/c.js\n", update(newRunner(&Options{}), false, "https://example.com/a", "https://example.com/e.js"))
+}
+
+func TestInventoryOptIn(t *testing.T) {
+	options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10}
+	require.Nil(t, options.validateOptions())
+	require.Empty(t, options.Inventory, "the inventory is not recorded unless asked")
+
+	options.Diff = true
+	require.Nil(t, options.validateOptions())
+	require.Equal(t, defaultInventoryLocation, options.Inventory)
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/jsfinder"
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/scope"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+// extractJSEndpoints downloads the in-scope javascript files among the found
+// urls and returns the in-scope endpoints they reference, labelled js:<script>
+func (r *Runner) extractJSEndpoints(ctx context.Context, domain string, domainScope *scope.Scope, hosts map[string]resolve.HostEntry) []subscraping.Result {
+	var scripts []string
+	for _, entry := range hosts {
+		if jsfinder.IsJavaScript(entry.Host) && domainScope.InScope(entry.Host) {
+			scripts = append(scripts, entry.Host)
+		}
+	}
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	gologger.Info().Msgf("Extracting endpoints from %d javascript files for %s\n", len(scripts), domain)
+
+	tasks := make(chan string)
+	mutex := &sync.Mutex{}
+	var results []subscraping.Result
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < r.options.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for script := range tasks {
+				endpoints, err := r.jsFinder.Find(ctx, script)
+				if err != nil {
+					gologger.Debug().Msgf("Could not extract endpoints from %s: %s\n", script, err)
+					continue
+				}
+				mutex.Lock()
+				for _, endpoint := range endpoints {
+					if domainScope.InScope(endpoint) {
+						results = append(results, subscraping.Result{Type: subscraping.URL, Source: "js:" + script, Value: endpoint})
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, script := range scripts {
+		if ctx.Err() != nil {
+			break
+		}
+		tasks <- script
+	}
+	close(tasks)
+	wg.Wait()
+
+	return results
+}
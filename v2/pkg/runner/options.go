@@ -16,6 +16,7 @@ import (
 
 	"github.com/chainreactors/urlfounder/v2/pkg/passive"
 	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	fileutil "github.com/projectdiscovery/utils/file"
@@ -25,6 +26,7 @@ import (
 var (
 	defaultConfigLocation         = filepath.Join(userHomeDir(), ".config/urlfounder/config.yaml")
 	defaultProviderConfigLocation = filepath.Join(userHomeDir(), ".config/urlfounder/provider-config.yaml")
+	defaultInventoryLocation      = filepath.Join(userHomeDir(), ".config/urlfounder/inventory")
 )
 
 // Options contains the configuration options for tuning
@@ -37,14 +39,27 @@ type Options struct {
 	ListSources        bool                // ListSources specifies whether to list all available sources
 	RemoveWildcard     bool                // RemoveWildcard specifies whether to remove potential wildcard or dead urls from the results.
 	CaptureSources     bool                // CaptureSources specifies whether to save all sources that returned a specific domains or just the first source
+	KeepScheme         bool                // KeepScheme specifies whether the http and https versions of an url are kept as distinct urls
+	Collapse           bool                // Collapse specifies whether to keep one url per host, path template and parameter names
+	StripTrailingSlash bool                // StripTrailingSlash specifies whether urls only differing by a trailing slash are deduplicated
+	Stream             bool                // Stream specifies whether to write each url as soon as it is found instead of at the end of enumeration
 	Stdin              bool                // Stdin specifies whether stdin input was given to the process
 	Version            bool                // Version specifies if we should just show version and exit
 	OnlyRecursive      bool                // Recursive specifies whether to use only recursive url enumeration sources
+	ExtractJS          bool                // ExtractJS specifies whether to extract endpoints from the discovered javascript files
 	All                bool                // All specifies whether to use all (slow) sources.
 	Statistics         bool                // Statistics specifies whether to report source statistics
+	ValidateKeys       bool                // ValidateKeys specifies whether to check the api keys of the provider config and exit
 	Threads            int                 // Threads controls the number of threads to use for active enumerations
+	DomainConcurrency  int                 // DomainConcurrency is the number of domains enumerated concurrently
 	Timeout            int                 // Timeout is the seconds to wait for sources to respond
 	MaxEnumerationTime int                 // MaxEnumerationTime is the maximum amount of time in minutes to wait for enumeration
+	PageSize           int                 // PageSize is the number of results to request per page from paginated sources
+	MaxPages           int                 // MaxPages is the maximum number of pages to fetch per source (0 = unlimited)
+	CommonCrawlIndexes int                 // CommonCrawlIndexes is the number of most recent common crawl indexes to query
+	CommonCrawlYears   string              // CommonCrawlYears is the year range of common crawl indexes to query (e.g. 2020-2023)
+	Since              string              // Since drops the urls last seen before a date or age (e.g. 2018-01-01 or 90d)
+	Until              string              // Until drops the urls first seen after a date or age
 	Domain             goflags.StringSlice // Domain is the domain to find urls for
 	DomainsFile        string              // DomainsFile is the file containing list of domains to find urls for
 	Output             io.Writer
@@ -55,15 +70,43 @@ type Options struct {
 	Resolvers          goflags.StringSlice `yaml:"resolvers,omitempty"`       // Resolvers is the comma-separated resolvers to use for enumeration
 	Config             string              // Config contains the location of the config file
 	ProviderConfig     string              // ProviderConfig contains the location of the provider config file
+	Resume             string              // Resume is the file the progress of the enumeration is saved to and resumed from
+	NoCache            bool                // NoCache specifies whether to neither read nor write the cached source results
+	Refresh            bool                // Refresh specifies whether to query the sources again, replacing their cached results
+	CacheTTL           time.Duration       // CacheTTL is how long the source results are cached
+	Inventory          string              // Inventory is the directory of the inventory recording the urls found by the runs, empty disables it
+	Diff               bool                // Diff specifies whether to write only the urls not found by the previous runs
+	DiffRemoved        bool                // DiffRemoved specifies whether to also write the urls found by the last run and not anymore
 	Proxy              string              // HTTP proxy
 	RateLimit          int                 // Maximum number of HTTP requests to send per second
+	RateLimits         goflags.StringSlice // RateLimits contains the per source rate limits as source=N requests per second
 	ResultCallback     OnResultCallback    // OnResult callback
 	DisableUpdateCheck bool                // DisableUpdateCheck disable update checking
 	StatusCode         bool                // StatusCode specifies whether to output status code for url
 	Match              goflags.StringSlice
 	Filter             goflags.StringSlice
+	Scope              goflags.StringSlice // Scope contains the in-scope rules, by default the domain and its subdomains are in scope
+	OutOfScope         goflags.StringSlice // OutOfScope contains the out-of-scope rules
+	ScopeFile          string              // ScopeFile is a file of scope rules, with out-of-scope rules prefixed by !
+	MatchRegex         goflags.StringSlice // MatchRegex contains the raw regexes, optionally prefixed by an url component, urls must match
+	FilterRegex        goflags.StringSlice // FilterRegex contains the raw regexes, optionally prefixed by an url component, of urls to drop
+	Extensions         goflags.StringSlice // Extensions contains the extensions or presets to keep
+	ExcludeExtensions  goflags.StringSlice // ExcludeExtensions contains the extensions or presets to drop
+	Mime               goflags.StringSlice // Mime contains the mime types or presets to keep, for sources reporting it
+	ExcludeMime        goflags.StringSlice // ExcludeMime contains the mime types or presets to drop, for sources reporting it
 	matchRegexes       []*regexp.Regexp
 	filterRegexes      []*regexp.Regexp
+	rawMatchRegexes    []*componentRegex
+	rawFilterRegexes   []*componentRegex
+	extensions         map[string]struct{}
+	excludeExtensions  map[string]struct{}
+	mimes              []string
+	excludeMimes       []string
+	yearFrom           int
+	yearTo             int
+	dates              subscraping.DateRange
+	sourceRateLimits   map[string]subscraping.RateLimit
+	sourceCacheTTLs    map[string]time.Duration
 	Title              bool // Title specifies whether to output titles for url
 }
 
@@ -104,16 +147,35 @@ func ParseOptions() *Options {
 		//flagSet.BoolVar(&options.OnlyRecursive, "recursive", false, "use only sources that can handle urls recursively (e.g. url.domain.tld vs domain.tld)"),
 		flagSet.BoolVar(&options.All, "all", false, "use all sources for enumeration (slow)"),
 		flagSet.StringSliceVarP(&options.ExcludeSources, "exclude-sources", "es", []string{}, "sources to exclude from enumeration (-es alienvault,zoomeye)", goflags.NormalizedStringSliceOptions),
+		flagSet.BoolVarP(&options.ExtractJS, "js-endpoints", "je", false, "extract endpoints from discovered javascript files"),
+		flagSet.IntVarP(&options.CommonCrawlIndexes, "cc-indexes", "cci", 3, "number of most recent common crawl indexes to query"),
+		flagSet.StringVarP(&options.CommonCrawlYears, "cc-years", "ccy", "", "year range of common crawl indexes to query (e.g. 2020-2023 or 2021)"),
 	)
 
 	createGroup(flagSet, "filter", "Filter",
 		flagSet.StringSliceVarP(&options.Match, "match", "m", []string{}, "url or list of url to match (file or comma separated)", goflags.FileNormalizedStringSliceOptions),
 		flagSet.StringSliceVarP(&options.Filter, "filter", "f", []string{}, " url or list of url to filter (file or comma separated)", goflags.FileNormalizedStringSliceOptions),
+		flagSet.StringSliceVar(&options.Scope, "scope", []string{}, "in-scope hosts (example.com, *.example.com), cidrs or host/path prefixes (file or comma separated)", goflags.FileCommaSeparatedStringSliceOptions),
+		flagSet.StringSliceVarP(&options.OutOfScope, "out-of-scope", "oos", []string{}, "out-of-scope hosts, cidrs or host/path prefixes (file or comma separated)", goflags.FileCommaSeparatedStringSliceOptions),
+		flagSet.StringVarP(&options.ScopeFile, "scope-file", "sf", "", "file of scope rules, out-of-scope rules prefixed by !"),
+		flagSet.StringSliceVarP(&options.MatchRegex, "match-regex", "mr", []string{}, "regex urls must match, may target a component with host:, path:, query: or param: (file or repeated flag)", goflags.FileStringSliceOptions),
+		flagSet.StringSliceVarP(&options.FilterRegex, "filter-regex", "fr", []string{}, "regex of urls to drop, may target a component with host:, path:, query: or param: (file or repeated flag)", goflags.FileStringSliceOptions),
+		flagSet.StringSliceVarP(&options.Extensions, "extensions", "ext", []string{}, "extensions or presets (static,docs,backup,js,config) of urls to keep", goflags.NormalizedStringSliceOptions),
+		flagSet.StringSliceVarP(&options.ExcludeExtensions, "exclude-extensions", "eext", []string{}, "extensions or presets (static,docs,backup,js,config) of urls to drop", goflags.NormalizedStringSliceOptions),
+		flagSet.StringSliceVar(&options.Mime, "mime", []string{}, "mime types (image/*) or presets of urls to keep, for sources reporting it", goflags.NormalizedStringSliceOptions),
+		flagSet.StringSliceVarP(&options.ExcludeMime, "exclude-mime", "emime", []string{}, "mime types (image/*) or presets of urls to drop, for sources reporting it", goflags.NormalizedStringSliceOptions),
+		flagSet.BoolVar(&options.Collapse, "collapse", false, "keep one url per host, path template and parameter names, dropping static assets and articles"),
+		flagSet.BoolVarP(&options.KeepScheme, "keep-scheme", "ks", false, "keep the http and https versions of an url as distinct urls"),
+		flagSet.BoolVarP(&options.StripTrailingSlash, "strip-trailing-slash", "sts", false, "deduplicate urls only differing by a trailing slash"),
+		flagSet.StringVar(&options.Since, "since", "", "keep urls seen since a date (2006, 2006-01, 2006-01-02) or age (90d, 12w, 6m, 1y), undated urls are kept"),
+		flagSet.StringVar(&options.Until, "until", "", "keep urls seen until a date (2006, 2006-01, 2006-01-02) or age (90d, 12w, 6m, 1y), undated urls are kept"),
 	)
 
 	createGroup(flagSet, "rate-limit", "Rate-limit",
-		flagSet.IntVarP(&options.RateLimit, "rate-limit", "rl", 0, "maximum number of http requests to send per second"),
+		flagSet.IntVarP(&options.RateLimit, "rate-limit", "rl", 0, "maximum number of http requests to send per second, shared by all sources and domains"),
+		flagSet.StringSliceVarP(&options.RateLimits, "rate-limits", "rls", nil, "maximum number of http requests to send per second to a source (e.g. virustotal=0.05,urlscan=1)", goflags.NormalizedStringSliceOptions),
 		flagSet.IntVar(&options.Threads, "t", 10, "number of concurrent goroutines for resolving (-active only)"),
+		flagSet.IntVarP(&options.DomainConcurrency, "domain-concurrency", "dc", 1, "number of domains to enumerate concurrently"),
 	)
 
 	createGroup(flagSet, "output", "Output",
@@ -121,8 +183,11 @@ func ParseOptions() *Options {
 		flagSet.BoolVarP(&options.JSON, "json", "oJ", false, "write output in JSONL(ines) format"),
 		flagSet.StringVarP(&options.OutputDirectory, "output-dir", "oD", "", "directory to write output (-dL only)"),
 		flagSet.BoolVarP(&options.CaptureSources, "collect-sources", "cs", false, "include all sources in the output (-json only)"),
+		flagSet.BoolVar(&options.Stream, "stream", false, "write urls as soon as they are found, with -cs each new source is written as an additional line"),
 		flagSet.BoolVarP(&options.StatusCode, "status", "sc", false, "include StatusCode in output"),
 		flagSet.BoolVarP(&options.Title, "title", "tI", false, "include url titles in output"),
+		flagSet.BoolVar(&options.Diff, "diff", false, "write only the urls not found by the previous runs"),
+		flagSet.BoolVarP(&options.DiffRemoved, "diff-removed", "dr", false, "also write the urls found by the last run and not anymore (-diff only)"),
 	)
 
 	createGroup(flagSet, "configuration", "Configuration",
@@ -131,6 +196,8 @@ func ParseOptions() *Options {
 		flagSet.StringSliceVar(&options.Resolvers, "r", []string{}, "comma separated list of resolvers to use", goflags.NormalizedStringSliceOptions),
 		flagSet.BoolVarP(&options.RemoveWildcard, "active", "aC", false, "display active urls only"),
 		flagSet.StringVar(&options.Proxy, "proxy", "", "http proxy to use with urlfounder"),
+		flagSet.StringVar(&options.Resume, "resume", "", "file to save the progress of the enumeration to, resuming it if the file exists"),
+		flagSet.StringVar(&options.Inventory, "inventory", "", "directory of the inventory recording the urls found by the runs (-diff uses $HOME/.config/urlfounder/inventory by default)"),
 	)
 
 	createGroup(flagSet, "debug", "Debug",
@@ -139,12 +206,18 @@ func ParseOptions() *Options {
 		flagSet.BoolVar(&options.Verbose, "v", false, "show verbose output"),
 		flagSet.BoolVarP(&options.NoColor, "no-color", "nc", false, "disable color in output"),
 		flagSet.BoolVarP(&options.ListSources, "list-sources", "ls", false, "list all available sources"),
+		flagSet.BoolVarP(&options.ValidateKeys, "validate-keys", "vk", false, "check the api keys of the provider config and exit"),
 		flagSet.BoolVar(&options.Statistics, "stats", false, "report source statistics"),
 	)
 
 	createGroup(flagSet, "optimization", "Optimization",
 		flagSet.IntVar(&options.Timeout, "timeout", 30, "seconds to wait before timing out"),
 		flagSet.IntVar(&options.MaxEnumerationTime, "max-time", 10, "minutes to wait for enumeration results"),
+		flagSet.IntVarP(&options.PageSize, "page-size", "ps", 0, "number of results to request per page from paginated sources (0 = source default)"),
+		flagSet.IntVarP(&options.MaxPages, "max-pages", "mp", 0, "maximum number of pages to fetch per source (0 = unlimited)"),
+		flagSet.DurationVarP(&options.CacheTTL, "cache-ttl", "ct", subscraping.DefaultCacheTTL, "how long the results of the sources are cached (0 = no cache)"),
+		flagSet.BoolVarP(&options.NoCache, "no-cache", "nca", false, "neither read nor write the cached results of the sources"),
+		flagSet.BoolVar(&options.Refresh, "refresh", false, "query the sources again, replacing their cached results"),
 	)
 
 	if err := flagSet.Parse(); err != nil {
@@ -181,17 +254,22 @@ func ParseOptions() *Options {
 
 	// Check if the application loading with any provider configuration, then take it
 	// Otherwise load the default provider config
+	providerConfig := defaultProviderConfigLocation
 	if fileutil.FileExists(options.ProviderConfig) {
 		gologger.Info().Msgf("Loading provider config from %s", options.ProviderConfig)
-		options.loadProvidersFrom(options.ProviderConfig)
+		providerConfig = options.ProviderConfig
 	} else {
 		gologger.Info().Msgf("Loading provider config from the default location: %s", defaultProviderConfigLocation)
-		options.loadProvidersFrom(defaultProviderConfigLocation)
 	}
+	options.loadProvidersFrom(providerConfig)
 	if options.ListSources {
 		listSources(options)
 		os.Exit(0)
 	}
+	if options.ValidateKeys {
+		validateKeys(options, providerConfig)
+		os.Exit(0)
+	}
 
 	// Validate the options passed by the user and if any
 	// invalid options have been used, exit.
@@ -215,6 +293,18 @@ func (options *Options) loadProvidersFrom(location string) {
 	if err := UnmarshalFrom(location); isFatalErr(err) && !errors.Is(err, os.ErrNotExist) {
 		gologger.Fatal().Msgf("Could not read providers from %s: %s\n", location, err)
 	}
+
+	rateLimits, err := UnmarshalRateLimitsFrom(location)
+	if isFatalErr(err) && !errors.Is(err, os.ErrNotExist) {
+		gologger.Fatal().Msgf("Could not read rate limits from %s: %s\n", location, err)
+	}
+	options.sourceRateLimits = rateLimits
+
+	cacheTTLs, err := UnmarshalCacheTTLsFrom(location)
+	if isFatalErr(err) && !errors.Is(err, os.ErrNotExist) {
+		gologger.Fatal().Msgf("Could not read cache ttls from %s: %s\n", location, err)
+	}
+	options.sourceCacheTTLs = cacheTTLs
 }
 
 func migrateToProviderConfig(defaultConfigLocation, defaultProviderLocation string) error {
@@ -7,10 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
 	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
 // OutputWriter outputs content to writers.
@@ -22,21 +24,68 @@ type jsonSourceResult struct {
 	Host   string `json:"host"`
 	Input  string `json:"input"`
 	Source string `json:"source"`
+	Count  int    `json:"count,omitempty"`
+	*jsonMetadata
 }
 
 type jsonSourceIPResult struct {
-	Host       string `json:"host"`
-	IP         string `json:"ip"`
-	Input      string `json:"input"`
-	Source     string `json:"source"`
-	StatusCode string `json:"statuscode"`
-	UrlTitle   string `json:"urltitle"`
+	Host          string   `json:"host"`
+	IP            string   `json:"ip"`
+	Input         string   `json:"input"`
+	Source        string   `json:"source"`
+	StatusCode    string   `json:"statuscode"`
+	UrlTitle      string   `json:"urltitle"`
+	ContentLength int64    `json:"content_length,omitempty"`
+	ContentType   string   `json:"content_type,omitempty"`
+	FinalURL      string   `json:"final_url,omitempty"`
+	RedirectChain []string `json:"redirect_chain,omitempty"`
+	Count         int      `json:"count,omitempty"`
+	*jsonMetadata
 }
 
 type jsonSourcesResult struct {
 	Host    string   `json:"host"`
 	Input   string   `json:"input"`
 	Sources []string `json:"sources"`
+	Count   int      `json:"count,omitempty"`
+	*jsonMetadata
+}
+
+type jsonRemovedResult struct {
+	Host   string `json:"host"`
+	Input  string `json:"input"`
+	Status string `json:"status"`
+}
+
+// jsonMetadata is the metadata of an url merged across its sources,
+// its fields are left out of the output when unknown
+type jsonMetadata struct {
+	FirstSeen      string            `json:"first_seen,omitempty"`
+	LastSeen       string            `json:"last_seen,omitempty"`
+	ArchivedStatus int               `json:"archived_status,omitempty"`
+	Mime           string            `json:"mime,omitempty"`
+	Hits           int               `json:"hits,omitempty"`
+	Extra          map[string]string `json:"extra,omitempty"`
+}
+
+// newJSONMetadata returns the json form of the metadata, nil when there is none
+func newJSONMetadata(metadata *subscraping.Metadata) *jsonMetadata {
+	if metadata == nil {
+		return nil
+	}
+	data := &jsonMetadata{
+		ArchivedStatus: metadata.StatusCode,
+		Mime:           metadata.Mime,
+		Hits:           metadata.Hits,
+		Extra:          metadata.Extra,
+	}
+	if !metadata.FirstSeen.IsZero() {
+		data.FirstSeen = metadata.FirstSeen.UTC().Format(time.RFC3339)
+	}
+	if !metadata.LastSeen.IsZero() {
+		data.LastSeen = metadata.LastSeen.UTC().Format(time.RFC3339)
+	}
+	return data
 }
 
 // NewOutputWriter creates a new OutputWriter
@@ -78,7 +127,7 @@ func (o *OutputWriter) createFile(filename string, appendToFile bool) (*os.File,
 func (o *OutputWriter) WriteHostNoWildcard(input string, results map[string]resolve.Result, writer io.Writer) error {
 	hosts := make(map[string]resolve.HostEntry)
 	for host, result := range results {
-		hosts[host] = resolve.HostEntry{Host: result.Host, Source: result.Source}
+		hosts[host] = resolve.HostEntry{Host: result.Host, Source: result.Source, Count: result.Count, Metadata: result.Metadata}
 	}
 
 	return o.WriteHost(input, hosts, writer)
@@ -121,6 +170,8 @@ func writeJSONHost(input string, results map[string]resolve.HostEntry, writer io
 		data.Host = result.Host
 		data.Input = input
 		data.Source = result.Source
+		data.Count = result.Count
+		data.jsonMetadata = newJSONMetadata(result.Metadata)
 		err := encoder.Encode(data)
 		if err != nil {
 			return err
@@ -129,18 +180,19 @@ func writeJSONHost(input string, results map[string]resolve.HostEntry, writer io
 	return nil
 }
 
-// WriteSourceHost writes the output list of url to an io.Writer
-func (o *OutputWriter) WriteSourceHost(input string, sourceMap map[string]map[string]struct{}, writer io.Writer) error {
+// WriteSourceHost writes the output list of url to an io.Writer, the count
+// and metadata of the urls, given by their host entry, are written in json only
+func (o *OutputWriter) WriteSourceHost(input string, sourceMap map[string]map[string]struct{}, hosts map[string]resolve.HostEntry, writer io.Writer) error {
 	var err error
 	if o.JSON {
-		err = writeSourceJSONHost(input, sourceMap, writer)
+		err = writeSourceJSONHost(input, sourceMap, hosts, writer)
 	} else {
 		err = writeSourcePlainHost(input, sourceMap, writer)
 	}
 	return err
 }
 
-func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{}, writer io.Writer) error {
+func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{}, hosts map[string]resolve.HostEntry, writer io.Writer) error {
 	encoder := jsoniter.NewEncoder(writer)
 
 	var data jsonSourcesResult
@@ -153,6 +205,8 @@ func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{},
 			keys = append(keys, source)
 		}
 		data.Sources = keys
+		data.Count = hosts[host].Count
+		data.jsonMetadata = newJSONMetadata(hosts[host].Metadata)
 
 		err := encoder.Encode(&data)
 		if err != nil {
@@ -186,6 +240,41 @@ func writeSourcePlainHost(_ string, sourceMap map[string]map[string]struct{}, wr
 	return bufwriter.Flush()
 }
 
+// WriteRemoved writes the urls not found anymore to an io.Writer, each
+// plain line is prefixed with a dash
+func (o *OutputWriter) WriteRemoved(input string, removed []string, writer io.Writer) error {
+	if o.JSON {
+		return writeJSONRemoved(input, removed, writer)
+	}
+	return writePlainRemoved(input, removed, writer)
+}
+
+func writePlainRemoved(_ string, removed []string, writer io.Writer) error {
+	bufwriter := bufio.NewWriter(writer)
+	for _, host := range removed {
+		if _, err := bufwriter.WriteString("- " + host + "\n"); err != nil {
+			bufwriter.Flush()
+			return err
+		}
+	}
+	return bufwriter.Flush()
+}
+
+func writeJSONRemoved(input string, removed []string, writer io.Writer) error {
+	encoder := jsoniter.NewEncoder(writer)
+
+	var data jsonRemovedResult
+	for _, host := range removed {
+		data.Host = host
+		data.Input = input
+		data.Status = "removed"
+		if err := encoder.Encode(&data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WriteStatusCode writes the output list of urls request status code to an io.Writer
 func (o *OutputWriter) WriteStatusCode(input string, results map[string]resolve.Result, writer io.Writer) error {
 	var err error
@@ -231,6 +320,12 @@ func writeJSONStatusCode(input string, results map[string]resolve.Result, writer
 		data.StatusCode = result.StatusCode
 		data.Input = input
 		data.Source = result.Source
+		data.ContentLength = result.ContentLength
+		data.ContentType = result.ContentType
+		data.FinalURL = result.FinalURL
+		data.RedirectChain = result.RedirectChain
+		data.Count = result.Count
+		data.jsonMetadata = newJSONMetadata(result.Metadata)
 
 		err := encoder.Encode(&data)
 		if err != nil {
@@ -288,6 +383,12 @@ func writeJSONStatusCodeAndTitle(input string, results map[string]resolve.Result
 		data.UrlTitle = result.UrlTitle
 		data.Input = input
 		data.Source = result.Source
+		data.ContentLength = result.ContentLength
+		data.ContentType = result.ContentType
+		data.FinalURL = result.FinalURL
+		data.RedirectChain = result.RedirectChain
+		data.Count = result.Count
+		data.jsonMetadata = newJSONMetadata(result.Metadata)
 
 		err := encoder.Encode(&data)
 		if err != nil {
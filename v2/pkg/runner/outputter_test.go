@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestWriteJSONHostMetadata(t *testing.T) {
+	metadata := &subscraping.Metadata{
+		FirstSeen:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode: 200,
+		Mime:       "text/html",
+		Hits:       2,
+	}
+
+	var buf bytes.Buffer
+	err := writeJSONHost("example.com", map[string]resolve.HostEntry{
+		"a": {Host: "https://example.com/a", Source: "webarchive", Metadata: metadata},
+	}, &buf)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"host":"https://example.com/a","input":"example.com","source":"webarchive","first_seen":"2020-01-01T00:00:00Z","archived_status":200,"mime":"text/html","hits":2}`, buf.String())
+
+	buf.Reset()
+	err = writeJSONHost("example.com", map[string]resolve.HostEntry{
+		"b": {Host: "https://example.com/b", Source: "urlscan"},
+	}, &buf)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"host":"https://example.com/b","input":"example.com","source":"urlscan"}`, buf.String())
+}
+
+func TestMergeMetadata(t *testing.T) {
+	require.Nil(t, mergeMetadata(nil, nil))
+
+	found := &subscraping.Metadata{Hits: 1}
+	metadata := mergeMetadata(nil, found)
+	require.Equal(t, found, metadata)
+	require.NotSame(t, found, metadata)
+
+	merged := mergeMetadata(metadata, &subscraping.Metadata{Hits: 2})
+	require.Equal(t, 3, merged.Hits)
+	require.Equal(t, 1, metadata.Hits, "merged metadata must not be modified in place")
+}
+
+func TestWriteRemoved(t *testing.T) {
+	removed := []string{"https://example.com/a", "https://example.com/b"}
+
+	var buf bytes.Buffer
+	require.Nil(t, NewOutputWriter(false).WriteRemoved("example.com", removed, &buf))
+	require.Equal(t, "- https://example.com/a\n- https://example.com/b\n", buf.String())
+
+	buf.Reset()
+	require.Nil(t, NewOutputWriter(true).WriteRemoved("example.com", removed[:1], &buf))
+	require.JSONEq(t, `{"host":"https://example.com/a","input":"example.com","status":"removed"}`, buf.String())
+}
+
+func TestWriteJSONCount(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeSourceJSONHost("example.com", map[string]map[string]struct{}{"https://example.com/a?id=1": {"webarchive": {}}},
+		map[string]resolve.HostEntry{"https://example.com/a?id=1": {Host: "https://example.com/a?id=1", Count: 3}}, &buf)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"host":"https://example.com/a?id=1","input":"example.com","sources":["webarchive"],"count":3}`, buf.String())
+
+	// The urls collapsed after the group was resolved are counted
+	results := withCounts(map[string]resolve.Result{"https://example.com/a?id=1": {Host: "https://example.com/a?id=1", StatusCode: "200", Count: 1}},
+		map[string]resolve.HostEntry{"example.com/a?id": {Host: "https://example.com/a?id=1", Count: 3}})
+	buf.Reset()
+	require.Nil(t, writeJSONStatusCode("example.com", results, &buf))
+	require.JSONEq(t, `{"host":"https://example.com/a?id=1","ip":"","input":"example.com","source":"","statuscode":"200","urltitle":"","count":3}`, buf.String())
+}
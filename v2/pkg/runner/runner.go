@@ -5,16 +5,24 @@ import (
 	"context"
 	"io"
 	"os"
+	"os/signal"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/pkg/errors"
 
 	"github.com/projectdiscovery/gologger"
 
+	"github.com/chainreactors/urlfounder/v2/pkg/inventory"
+	"github.com/chainreactors/urlfounder/v2/pkg/jsfinder"
+	"github.com/chainreactors/urlfounder/v2/pkg/normalize"
 	"github.com/chainreactors/urlfounder/v2/pkg/passive"
 	"github.com/chainreactors/urlfounder/v2/pkg/resolve"
+	"github.com/chainreactors/urlfounder/v2/pkg/scope"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
 // Runner is an instance of the url enumeration
@@ -23,6 +31,13 @@ type Runner struct {
 	options        *Options
 	passiveAgent   *passive.Agent
 	resolverClient *resolve.Resolver
+	normalizer     *normalize.Normalizer
+	scope          *scope.Scope
+	keys           *subscraping.KeyManager
+	checkpoint     *checkpoint
+	inventory      *inventory.Inventory
+	jsFinder       *jsfinder.Finder
+	outputMutex    sync.Mutex
 }
 
 // NewRunner creates a new runner struct instance by parsing
@@ -31,9 +46,30 @@ type Runner struct {
 func NewRunner(options *Options) (*Runner, error) {
 	runner := &Runner{options: options}
 
+	// Initialize the url normalizer used for deduplication
+	runner.initializeNormalizer()
+
+	// Initialize the scope the found urls are checked against
+	if err := runner.initializeScope(); err != nil {
+		return nil, err
+	}
+
 	// Initialize the passive url enumeration engine
 	runner.initializePassiveEngine()
 
+	// Initialize the javascript endpoint extraction shared by the domains
+	if err := runner.initializeJSFinder(); err != nil {
+		return nil, err
+	}
+
+	// Load the progress of the interrupted run to resume
+	if err := runner.initializeCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	// Open the inventory the urls found are recorded in
+	runner.initializeInventory()
+
 	// Initialize the url resolver
 	err := runner.initializeResolver()
 	if err != nil {
@@ -50,6 +86,49 @@ func (r *Runner) RunEnumeration() error {
 
 // RunEnumerationWithCtx runs the url enumeration flow on the targets specified
 func (r *Runner) RunEnumerationWithCtx(ctx context.Context) error {
+	var err error
+	if r.checkpoint != nil {
+		err = r.runResumableEnumeration(ctx)
+	} else {
+		err = r.runEnumeration(ctx)
+	}
+
+	r.saveKeyState()
+	if r.options.Statistics {
+		printKeyStatistics(r.keys.Statistics())
+	}
+	return err
+}
+
+// runResumableEnumeration enumerates the domains of the input, saving its
+// progress periodically and when interrupted so that the next run resumes it
+func (r *Runner) runResumableEnumeration(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	saveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go r.checkpoint.saveEvery(saveCtx, checkpointInterval)
+
+	err := r.runEnumeration(ctx)
+	if err == nil && ctx.Err() == nil {
+		// Everything was written, the next run starts over
+		if removeErr := r.checkpoint.remove(); removeErr != nil {
+			gologger.Warning().Msgf("Could not remove checkpoint %s: %s\n", r.options.Resume, removeErr)
+		}
+		return nil
+	}
+
+	if saveErr := r.checkpoint.save(); saveErr != nil {
+		gologger.Error().Msgf("Could not save checkpoint to %s: %s\n", r.options.Resume, saveErr)
+	} else {
+		gologger.Info().Msgf("Progress saved to %s, run again with -resume %s to continue\n", r.options.Resume, r.options.Resume)
+	}
+	return err
+}
+
+// runEnumeration enumerates the domains of the input
+func (r *Runner) runEnumeration(ctx context.Context) error {
 	outputs := []io.Writer{r.options.Output}
 
 	if len(r.options.Domain) > 0 {
@@ -80,57 +159,111 @@ func (r *Runner) EnumerateMultipleURLs(reader io.Reader, writers []io.Writer) er
 	return r.EnumerateMultipleURLsWithCtx(context.Background(), reader, writers)
 }
 
-// EnumerateMultipleURLsWithCtx enumerates urls for multiple domains
-// We keep enumerating urls for a given domain until we reach an error
+// EnumerateMultipleURLsWithCtx enumerates urls for multiple domains,
+// up to DomainConcurrency domains are enumerated at the same time.
+// We keep enumerating urls for the given domains until we reach an error
 func (r *Runner) EnumerateMultipleURLsWithCtx(ctx context.Context, reader io.Reader, writers []io.Writer) error {
+	// If the user has specified an output file, use that output file instead
+	// of creating a new output file for each domain. The file is shared by
+	// every domain, writes are serialized by writeResults.
+	if r.options.OutputFile != "" {
+		outputWriter := NewOutputWriter(r.options.JSON)
+		file, err := outputWriter.createFile(r.options.OutputFile, true)
+		if err != nil {
+			gologger.Error().Msgf("Could not create file %s: %s\n", r.options.OutputFile, err)
+			return err
+		}
+		defer file.Close()
+
+		writers = append(writers, file)
+	}
+
+	concurrency := r.options.DomainConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	errMutex := &sync.Mutex{}
+	domains := make(chan string)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range domains {
+				if err := r.enumerateDomain(ctx, domain, writers); err != nil {
+					errMutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+						// Stop the other domains on the first error
+						cancel()
+					}
+					errMutex.Unlock()
+				}
+			}
+		}()
+	}
+
 	scanner := bufio.NewScanner(reader)
 	ip, _ := regexp.Compile(`^([0-9\.]+$)`)
+	seen := make(map[string]struct{})
 	for scanner.Scan() {
 		domain, err := sanitize(scanner.Text())
 		isIp := ip.MatchString(domain)
 		if errors.Is(err, ErrEmptyInput) || (isIp) {
 			continue
 		}
+		// A domain listed twice would be written twice to the same -oD file
+		if _, ok := seen[domain]; ok {
+			continue
+		}
+		seen[domain] = struct{}{}
+		if r.checkpoint.isDone(domain) {
+			gologger.Info().Msgf("Skipping %s, enumerated by the previous run\n", domain)
+			continue
+		}
 
-		var file *os.File
-		// If the user has specified an output file, use that output file instead
-		// of creating a new output file for each domain. Else create a new file
-		// for each domain in the directory.
-		if r.options.OutputFile != "" {
-			outputWriter := NewOutputWriter(r.options.JSON)
-			file, err = outputWriter.createFile(r.options.OutputFile, true)
-			if err != nil {
-				gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, r.options.Domain, err)
-				return err
-			}
-
-			err = r.EnumerateSingleURLWithCtx(ctx, domain, append(writers, file))
+		select {
+		case domains <- domain:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(domains)
+	wg.Wait()
 
-			file.Close()
-		} else if r.options.OutputDirectory != "" {
-			outputFile := path.Join(r.options.OutputDirectory, domain)
-			if r.options.JSON {
-				outputFile += ".json"
-			} else {
-				outputFile += ".txt"
-			}
+	return firstErr
+}
 
-			outputWriter := NewOutputWriter(r.options.JSON)
-			file, err = outputWriter.createFile(outputFile, false)
-			if err != nil {
-				gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, r.options.Domain, err)
-				return err
-			}
+// enumerateDomain enumerates the domain, writing the results in its own
+// file when an output directory is given
+func (r *Runner) enumerateDomain(ctx context.Context, domain string, writers []io.Writer) error {
+	if r.options.OutputFile != "" || r.options.OutputDirectory == "" {
+		return r.EnumerateSingleURLWithCtx(ctx, domain, writers)
+	}
 
-			err = r.EnumerateSingleURLWithCtx(ctx, domain, append(writers, file))
+	outputFile := path.Join(r.options.OutputDirectory, domain)
+	if r.options.JSON {
+		outputFile += ".json"
+	} else {
+		outputFile += ".txt"
+	}
 
-			file.Close()
-		} else {
-			err = r.EnumerateSingleURLWithCtx(ctx, domain, writers)
-		}
-		if err != nil {
-			return err
-		}
+	// The urls streamed before an interruption are kept in the file
+	outputWriter := NewOutputWriter(r.options.JSON)
+	file, err := outputWriter.createFile(outputFile, r.options.Stream && r.checkpoint.isStarted(domain))
+	if err != nil {
+		gologger.Error().Msgf("Could not create file %s for %s: %s\n", outputFile, domain, err)
+		return err
 	}
-	return nil
+	defer file.Close()
+
+	// Copy the writers, the slice is shared with the other domains
+	return r.EnumerateSingleURLWithCtx(ctx, domain, append(append([]io.Writer{}, writers...), file))
 }
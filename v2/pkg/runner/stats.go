@@ -18,20 +18,30 @@ func printStatistics(stats map[string]subscraping.Statistics) {
 
 	var lines []string
 	var skipped []string
+	var hits, misses int
 
 	for _, source := range sources {
 		sourceStats := stats[source]
 		if sourceStats.Skipped {
 			skipped = append(skipped, fmt.Sprintf(" %s", source))
 		} else {
-			lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d", source, sourceStats.TimeTaken.Round(time.Millisecond).String(), sourceStats.Results, sourceStats.Errors))
+			lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d %10d %7s", source, sourceStats.TimeTaken.Round(time.Millisecond).String(), sourceStats.Results, sourceStats.Errors, sourceStats.Retries, sourceStats.Cache))
+		}
+		switch sourceStats.Cache {
+		case subscraping.CacheHit:
+			hits++
+		case subscraping.CacheMiss:
+			misses++
 		}
 	}
 
 	if len(lines) > 0 {
-		gologger.Print().Msgf("\n Source               Duration      Results     Errors\n%s\n", strings.Repeat("─", 56))
+		gologger.Print().Msgf("\n Source               Duration      Results     Errors    Retries   Cache\n%s\n", strings.Repeat("─", 75))
 		gologger.Print().Msgf(strings.Join(lines, "\n"))
 		gologger.Print().Msgf("\n")
+		if hits+misses > 0 {
+			gologger.Print().Msgf("\n Cache: %d hits, %d misses\n", hits, misses)
+		}
 	}
 
 	if len(skipped) > 0 {
@@ -40,3 +50,38 @@ func printStatistics(stats map[string]subscraping.Statistics) {
 		gologger.Print().Msgf("\n\n")
 	}
 }
+
+// printDropped reports the number of urls dropped per source for the reason
+func printDropped(domain, reason string, dropped map[string]int) {
+	if len(dropped) == 0 {
+		return
+	}
+	sources := maps.Keys(dropped)
+	sort.Strings(sources)
+
+	counts := make([]string, 0, len(sources))
+	for _, source := range sources {
+		counts = append(counts, fmt.Sprintf("%s=%d", source, dropped[source]))
+	}
+	gologger.Info().Msgf("Dropped %s urls for %s: %s\n", reason, domain, strings.Join(counts, ", "))
+}
+
+// printKeyStatistics reports the usage of the api keys during the run
+func printKeyStatistics(stats []subscraping.KeyStatistics) {
+	if len(stats) == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(stats))
+	for _, keyStats := range stats {
+		state := keyStats.State.String()
+		if keyStats.State != subscraping.KeyOK {
+			state += " until " + keyStats.Until.Local().Format("2006-01-02 15:04")
+		}
+		lines = append(lines, fmt.Sprintf(" %-20s %-16s %10d %10d   %s", keyStats.Source, subscraping.MaskKey(keyStats.Key), keyStats.Requests, keyStats.Failures, state))
+	}
+
+	gologger.Print().Msgf("\n Source               Key                Requests   Failures   State\n%s\n", strings.Repeat("─", 80))
+	gologger.Print().Msgf(strings.Join(lines, "\n"))
+	gologger.Print().Msgf("\n")
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/chainreactors/urlfounder/v2/pkg/passive"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/formatter"
 	"github.com/projectdiscovery/gologger/levels"
@@ -25,19 +29,58 @@ func (options *Options) validateOptions() error {
 	}
 
 	// Validate threads and options
-	if options.Threads == 0 {
-		return errors.New("threads cannot be zero")
+	if options.Threads < 1 {
+		return errors.New("threads must be at least one")
 	}
 	if options.Timeout == 0 {
 		return errors.New("timeout cannot be zero")
 	}
 
+	if options.CommonCrawlYears != "" {
+		var err error
+		if options.yearFrom, options.yearTo, err = parseYearRange(options.CommonCrawlYears); err != nil {
+			return err
+		}
+	}
+
+	// The urls of a group are counted once the enumeration is done
+	if options.Collapse && options.Stream {
+		return errors.New("-collapse cannot be used with -stream")
+	}
+
+	// The urls are diffed against the ones recorded in the inventory,
+	// the default one unless another one is given
+	if options.Diff && options.Inventory == "" {
+		options.Inventory = defaultInventoryLocation
+	}
+	if options.DiffRemoved && !options.Diff {
+		return errors.New("-diff-removed can only be used with -diff")
+	}
+
+	if err := options.parseDateRange(time.Now()); err != nil {
+		return err
+	}
+
+	options.extensions = expandExtensions(options.Extensions)
+	options.excludeExtensions = expandExtensions(options.ExcludeExtensions)
+	var err error
+	if options.mimes, err = expandMimes(options.Mime); err != nil {
+		return err
+	}
+	if options.excludeMimes, err = expandMimes(options.ExcludeMime); err != nil {
+		return err
+	}
+	// The mime type of an url is known once every source has reported it
+	if options.Stream && (len(options.mimes) > 0 || len(options.excludeMimes) > 0) {
+		return errors.New("-mime and -exclude-mime cannot be used with -stream")
+	}
+
 	if options.Match != nil {
 		options.matchRegexes = make([]*regexp.Regexp, len(options.Match))
 		var err error
 		for i, re := range options.Match {
 			if options.matchRegexes[i], err = regexp.Compile(stripRegexString(re)); err != nil {
-				return errors.New("invalid value for match regex option")
+				return fmt.Errorf("invalid value %q for match option: %s", re, err)
 			}
 		}
 	}
@@ -46,12 +89,125 @@ func (options *Options) validateOptions() error {
 		var err error
 		for i, re := range options.Filter {
 			if options.filterRegexes[i], err = regexp.Compile(stripRegexString(re)); err != nil {
-				return errors.New("invalid value for filter regex option")
+				return fmt.Errorf("invalid value %q for filter option: %s", re, err)
 			}
 		}
 	}
+	if options.rawMatchRegexes, err = compileComponentRegexes("match-regex", options.MatchRegex); err != nil {
+		return err
+	}
+	if options.rawFilterRegexes, err = compileComponentRegexes("filter-regex", options.FilterRegex); err != nil {
+		return err
+	}
+	return options.parseRateLimits()
+}
+
+// parseRateLimits adds the rate limits given on the command line
+// to the ones read from the provider config, overriding them
+func (options *Options) parseRateLimits() error {
+	if len(options.RateLimits) == 0 {
+		return nil
+	}
+	if options.sourceRateLimits == nil {
+		options.sourceRateLimits = make(map[string]subscraping.RateLimit)
+	}
+	for _, value := range options.RateLimits {
+		source, limit, err := subscraping.ParseRateLimit(value)
+		if err != nil {
+			return err
+		}
+		if _, ok := passive.NameSourceMap[source]; !ok {
+			return fmt.Errorf("invalid rate limit %q, there is no source with the name %s", value, source)
+		}
+		options.sourceRateLimits[source] = options.sourceRateLimits[source].Merge(limit)
+	}
 	return nil
 }
+
+// parseYearRange parses a single year or an inclusive range of years
+func parseYearRange(value string) (int, int, error) {
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		to = from
+	}
+	yearFrom, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid year range %q", value)
+	}
+	yearTo, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid year range %q", value)
+	}
+	if yearFrom > yearTo {
+		return 0, 0, fmt.Errorf("invalid year range %q: start is after end", value)
+	}
+	return yearFrom, yearTo, nil
+}
+
+// dateLayouts are the layouts of the dates given to -since and -until, by precision
+var dateLayouts = []struct {
+	layout string
+	years  int
+	months int
+	days   int
+}{
+	{"2006-01-02", 0, 0, 1},
+	{"2006-01", 0, 1, 0},
+	{"2006", 1, 0, 0},
+}
+
+// parseDateRange parses the bounds of -since and -until, relative to now
+func (options *Options) parseDateRange(now time.Time) error {
+	var err error
+	if options.Since != "" {
+		if options.dates.Since, err = parseDate(options.Since, now, false); err != nil {
+			return err
+		}
+	}
+	if options.Until != "" {
+		if options.dates.Until, err = parseDate(options.Until, now, true); err != nil {
+			return err
+		}
+	}
+	if !options.dates.Since.IsZero() && !options.dates.Until.IsZero() && options.dates.Since.After(options.dates.Until) {
+		return fmt.Errorf("invalid date range: %s is after %s", options.Since, options.Until)
+	}
+	return nil
+}
+
+// parseDate parses a date, a day, month or year, or an age such as 90d. The
+// end of a period is returned for an upper bound so that it is inclusive.
+func parseDate(value string, now time.Time, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout.layout, value); err == nil {
+			if end {
+				date = date.AddDate(layout.years, layout.months, layout.days).Add(-time.Second)
+			}
+			return date, nil
+		}
+	}
+
+	if len(value) > 1 {
+		if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n >= 0 {
+			switch value[len(value)-1] {
+			case 'd':
+				return now.AddDate(0, 0, -n), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n), nil
+			case 'm':
+				return now.AddDate(0, -n, 0), nil
+			case 'y':
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected a date such as 2018-01-02 or an age such as 90d", value)
+}
+
 func stripRegexString(val string) string {
 	val = strings.ReplaceAll(val, ".", "\\.")
 	val = strings.ReplaceAll(val, "*", ".*")
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/passive"
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+// keyStatus is the status of an api key reported by -validate-keys
+type keyStatus struct {
+	key    string
+	source string
+	status string
+	quota  string
+}
+
+// validateKeys probes every api key of the provider config and prints their status
+func validateKeys(options *Options, location string) {
+	sourceKeys, err := UnmarshalKeysFrom(location)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not read providers from %s: %s\n", location, err)
+	}
+
+	session, err := subscraping.NewSession("", options.Proxy, 0, options.Timeout)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not init session: %s\n", err)
+	}
+
+	var statuses []keyStatus
+	for _, source := range passive.AllSources {
+		// The sources without keys configured are left out, among them the ones taking no key
+		keys := sourceKeys[strings.ToLower(source.Name())]
+		if !source.NeedsKey() || len(keys) == 0 {
+			continue
+		}
+		statuses = append(statuses, probeKeys(source, keys, session, time.Duration(options.Timeout)*time.Second)...)
+	}
+
+	gologger.Info().Msgf("Validated the api keys of %s\n\n", location)
+	gologger.Silent().Msgf(" %-16s %-14s %-14s %s\n%s\n", "Key", "Source", "Status", "Quota", strings.Repeat("─", 70))
+	for _, status := range statuses {
+		gologger.Silent().Msgf(" %-16s %-14s %-14s %s\n", status.key, status.source, status.status, status.quota)
+	}
+}
+
+// probeKeys checks the keys of the source with its probe, if it has one
+func probeKeys(source subscraping.Source, keys []string, session *subscraping.Session, timeout time.Duration) []keyStatus {
+	validator, ok := source.(subscraping.KeyValidator)
+	statuses := make([]keyStatus, 0, len(keys))
+	for _, key := range keys {
+		status := keyStatus{key: subscraping.MaskKey(key), source: source.Name(), status: "unverifiable"}
+		if ok {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			probe, err := validator.ValidateKey(ctx, key, session)
+			cancel()
+
+			switch {
+			case err != nil:
+				status.status = "error"
+				status.quota = fmt.Sprint(err)
+			case probe.Valid:
+				status.status, status.quota = "valid", probe.Quota
+			default:
+				status.status = "invalid"
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,3 @@
+// Package scope decides which of the found urls are
+// in scope of the enumeration.
+package scope
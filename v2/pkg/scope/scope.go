@@ -0,0 +1,173 @@
+package scope
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Rule matches urls by host and optionally by path prefix
+type Rule struct {
+	// host is the exact host, or the parent domain when wildcard is set
+	host     string
+	wildcard bool
+	// network matches ip hosts of a cidr rule
+	network *net.IPNet
+	// pathPrefix restricts the rule to paths starting with it
+	pathPrefix string
+}
+
+// ParseRule parses a rule which is either an exact host (example.com), a
+// wildcard matching every subdomain (*.example.com) or a cidr (10.0.0.0/8),
+// each optionally followed by a path prefix (example.com/api). A scheme in
+// front of the rule is ignored.
+func ParseRule(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "://"); i >= 0 {
+		value = value[i+3:]
+	}
+	if value == "" {
+		return nil, fmt.Errorf("empty scope rule")
+	}
+
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return &Rule{network: network}, nil
+	}
+
+	host, path := value, ""
+	if i := strings.Index(value, "/"); i >= 0 {
+		host, path = value[:i], value[i:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	rule := &Rule{host: host, pathPrefix: path}
+	if strings.HasPrefix(host, "*.") {
+		rule.host = host[2:]
+		rule.wildcard = true
+	}
+	if rule.host == "" || strings.Contains(rule.host, "*") {
+		return nil, fmt.Errorf("invalid scope rule %q: wildcards are only supported as *.domain", value)
+	}
+	return rule, nil
+}
+
+// Match returns true if the host and path are covered by the rule
+func (r *Rule) Match(host, path string) bool {
+	if r.network != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.network.Contains(ip)
+	}
+	if r.wildcard {
+		if !strings.HasSuffix(host, "."+r.host) {
+			return false
+		}
+	} else if host != r.host {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	// The prefix ends on a segment boundary, /private does not cover /privateer
+	if !strings.HasPrefix(path, r.pathPrefix) {
+		return false
+	}
+	rest := path[len(r.pathPrefix):]
+	return rest == "" || rest[0] == '/' || strings.HasSuffix(r.pathPrefix, "/")
+}
+
+// Scope holds the in-scope and out-of-scope rules
+type Scope struct {
+	include []*Rule
+	exclude []*Rule
+}
+
+// New creates a scope from the include and exclude rules
+func New(include, exclude []string) (*Scope, error) {
+	scope := &Scope{}
+	for _, value := range include {
+		rule, err := ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		scope.include = append(scope.include, rule)
+	}
+	for _, value := range exclude {
+		rule, err := ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		scope.exclude = append(scope.exclude, rule)
+	}
+	return scope, nil
+}
+
+// LoadFile reads a scope file holding a rule per line. Lines starting
+// with ! are out-of-scope rules and lines starting with # are comments.
+func LoadFile(file string) (include, exclude []string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "!"):
+			exclude = append(exclude, strings.TrimSpace(line[1:]))
+		default:
+			include = append(include, line)
+		}
+	}
+	return include, exclude, scanner.Err()
+}
+
+// ForDomain returns the scope applied to the domain. Without include rules
+// the domain and its subdomains are in scope, the exclude rules always apply.
+func (s *Scope) ForDomain(domain string) *Scope {
+	if len(s.include) > 0 {
+		return s
+	}
+	domain = strings.ToLower(domain)
+	return &Scope{
+		include: []*Rule{{host: domain}, {host: domain, wildcard: true}},
+		exclude: s.exclude,
+	}
+}
+
+// InScope returns true if the url matches an include rule and no exclude
+// rule. Urls without a scheme are read as http urls.
+func (s *Scope) InScope(rawURL string) bool {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + strings.TrimPrefix(rawURL, "//")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+
+	for _, rule := range s.exclude {
+		if rule.Match(host, parsed.Path) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, rule := range s.include {
+		if rule.Match(host, parsed.Path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package scope
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInScope(t *testing.T) {
+	scope, err := New([]string{"example.com", "*.example.com", "https://shop.other.com/store", "10.0.0.0/8"}, []string{"admin.example.com", "example.com/private"})
+	require.Nil(t, err)
+
+	inScope := []string{
+		"https://example.com/",
+		"http://www.example.com:8080/login",
+		"https://shop.other.com/store/item?id=1",
+		"http://10.1.2.3/status",
+		"example.com/path",
+		"https://example.com/privateer",
+		"https://example.com/private-old/keys",
+	}
+	for _, value := range inScope {
+		require.True(t, scope.InScope(value), value)
+	}
+
+	outOfScope := []string{
+		"https://notexample.com/",
+		"https://example.com.evil.com/",
+		"https://shop.other.com/",
+		"https://shop.other.com/stores",
+		"http://192.168.1.1/",
+		"https://admin.example.com/",
+		"https://example.com/private",
+		"https://example.com/private/keys",
+	}
+	for _, value := range outOfScope {
+		require.False(t, scope.InScope(value), value)
+	}
+}
+
+func TestForDomain(t *testing.T) {
+	scope, err := New(nil, []string{"*.cdn.example.com"})
+	require.Nil(t, err)
+
+	domainScope := scope.ForDomain("Example.com")
+	require.True(t, domainScope.InScope("https://example.com/"))
+	require.True(t, domainScope.InScope("https://api.example.com/v1"))
+	require.False(t, domainScope.InScope("https://static.cdn.example.com/a.js"))
+	require.False(t, domainScope.InScope("https://exampleXcom.org/"))
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	_, err := ParseRule("ex*ample.com")
+	require.NotNil(t, err)
+	_, err = ParseRule(" ")
+	require.NotNil(t, err)
+}
+
+func TestLoadFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scope.txt")
+	require.Nil(t, os.WriteFile(file, []byte("# program scope\n*.example.com\n\n!legacy.example.com\n"), 0644))
+
+	include, exclude, err := LoadFile(file)
+	require.Nil(t, err)
+	require.Equal(t, []string{"*.example.com"}, include)
+	require.Equal(t, []string{"legacy.example.com"}, exclude)
+}
@@ -4,15 +4,16 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/corpix/uarand"
-	"github.com/projectdiscovery/ratelimit"
 
 	"github.com/projectdiscovery/gologger"
 )
@@ -46,14 +47,10 @@ func NewSession(domain string, proxy string, rateLimit, timeout int) (*Session,
 		Timeout:   time.Duration(timeout) * time.Second,
 	}
 
-	session := &Session{Client: client}
+	session := &Session{Client: client, Retry: DefaultRetryPolicy, Keys: NewKeyManager()}
 
 	// Initiate rate limit instance
-	if rateLimit > 0 {
-		session.RateLimiter = ratelimit.New(context.Background(), uint(rateLimit), time.Second)
-	} else {
-		session.RateLimiter = ratelimit.NewUnlimited(context.Background())
-	}
+	session.RateLimiter = NewLimiter(RateLimit{PerSecond: float64(rateLimit)})
 
 	// Create a new extractor object for the current domain
 	extractor, err := NewURLExtractor(domain)
@@ -82,8 +79,52 @@ func (s *Session) SimplePost(ctx context.Context, postURL, contentType string, b
 	return s.HTTPRequest(ctx, http.MethodPost, postURL, "", map[string]string{"Content-Type": contentType}, body, BasicAuth{})
 }
 
-// HTTPRequest makes any HTTP request to a URL with extended parameters
+// HTTPRequest makes any HTTP request to a URL with extended parameters.
+// Failed requests are retried following the retry policy of the context, or the session one.
 func (s *Session) HTTPRequest(ctx context.Context, method, requestURL, cookies string, headers map[string]string, body io.Reader, basicAuth BasicAuth) (*http.Response, error) {
+	// The body is read once so that it can be sent again on retries
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := s.retryPolicy(ctx)
+	for attempt := 0; ; attempt++ {
+		resp, err := s.attempt(ctx, method, requestURL, cookies, headers, payload, basicAuth)
+		if attempt >= policy.MaxRetries || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		// Give up right away if the enumeration ends before the retry
+		wait := policy.delay(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+		s.DiscardHTTPResponse(resp)
+		atomic.AddInt32(&s.retries, 1)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Retries returns the number of requests retried by the session
+func (s *Session) Retries() int {
+	return int(atomic.LoadInt32(&s.retries))
+}
+
+// attempt sends the request once
+func (s *Session) attempt(ctx context.Context, method, requestURL, cookies string, headers map[string]string, payload []byte, basicAuth BasicAuth) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
 	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
 	if err != nil {
 		return nil, err
@@ -106,7 +147,11 @@ func (s *Session) HTTPRequest(ctx context.Context, method, requestURL, cookies s
 		req.Header.Set(key, value)
 	}
 
-	s.RateLimiter.Take()
+	release, err := s.RateLimiter.Take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	return httpRequestWrapper(s.Client, req)
 }
@@ -126,11 +171,15 @@ func (s *Session) DiscardHTTPResponse(response *http.Response) {
 func httpRequestWrapper(client *http.Client, request *http.Request) (*http.Response, error) {
 	response, err := client.Do(request)
 	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(request.URL)
+		}
 		return nil, err
 	}
 
 	if response.StatusCode != http.StatusOK {
-		requestURL, _ := url.QueryUnescape(request.URL.String())
+		requestURL := redactURL(request.URL)
 
 		gologger.Debug().MsgFunc(func() string {
 			buffer := new(bytes.Buffer)
@@ -141,3 +190,15 @@ func httpRequestWrapper(client *http.Client, request *http.Request) (*http.Respo
 	}
 	return response, nil
 }
+
+// redactURL returns the url without its query string and credentials,
+// which may hold the api key of the source
+func redactURL(requestURL *url.URL) string {
+	redacted := *requestURL
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.ForceQuery = false
+	redacted.Fragment = ""
+	redacted.RawFragment = ""
+	return redacted.String()
+}
@@ -0,0 +1,218 @@
+package subscraping
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheTTL is how long the results of a source are cached by default
+const DefaultCacheTTL = 24 * time.Hour
+
+// CacheStatus tells whether the results of a source were read from the cache
+type CacheStatus int
+
+// Cache statuses of a source
+const (
+	CacheUnused CacheStatus = iota
+	CacheHit
+	CacheMiss
+)
+
+// String returns the name of the status
+func (c CacheStatus) String() string {
+	switch c {
+	case CacheHit:
+		return "hit"
+	case CacheMiss:
+		return "miss"
+	default:
+		return "-"
+	}
+}
+
+// Cache keeps the urls found by the sources on disk so that enumerating
+// a domain again within their ttl does not query the sources again. The
+// results of each query are kept in their own file, one url per line.
+type Cache struct {
+	dir string
+	// TTL is how long the results of the sources are kept
+	TTL time.Duration
+	// TTLs overrides the ttl of the sources by name, zero disables the cache of a source
+	TTLs map[string]time.Duration
+	// Refresh ignores the cached results, the new ones replace them
+	Refresh bool
+}
+
+// cachedResult is an url kept in the cache
+type cachedResult struct {
+	Value    string    `json:"value"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// NewCache creates a cache keeping its files in the directory
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir, TTL: DefaultCacheTTL, TTLs: make(map[string]time.Duration)}
+}
+
+// CacheKey returns the key of the results of the source for the domain,
+// the session parameters changing the results of the sources are part of it
+func CacheKey(source, domain string, session *Session) string {
+	// The range is rounded to the day the sources filter on, so that
+	// a relative range such as -since 30d keeps its key during the day
+	from, to := session.Dates.Days()
+	query := fmt.Sprintf("%s|%s|%d|%d|%d|%d|%d|%s|%s", source, domain, session.PageSize, session.MaxPages,
+		session.MaxIndexes, session.YearFrom, session.YearTo, from, to)
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Enabled returns true if the results of the source are cached
+func (c *Cache) Enabled(source string) bool {
+	return c.ttl(source) > 0
+}
+
+func (c *Cache) ttl(source string) time.Duration {
+	if ttl, ok := c.TTLs[source]; ok {
+		return ttl
+	}
+	return c.TTL
+}
+
+func (c *Cache) file(source, key string) string {
+	return filepath.Join(c.dir, source, key+".jsonl")
+}
+
+// Load returns the cached urls of the source for the key, if they are
+// not older than the ttl of the source
+func (c *Cache) Load(source, key string) ([]Result, bool) {
+	if c.Refresh || !c.Enabled(source) {
+		return nil, false
+	}
+
+	file := c.file(source, key)
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) > c.ttl(source) {
+		os.Remove(file)
+		return nil, false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var results []Result
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var cached cachedResult
+		if err := json.Unmarshal(scanner.Bytes(), &cached); err != nil {
+			return nil, false
+		}
+		results = append(results, Result{Source: source, Type: URL, Value: cached.Value, Metadata: cached.Metadata})
+	}
+	if scanner.Err() != nil {
+		return nil, false
+	}
+	return results, true
+}
+
+// Prune removes the cached results older than the ttl of their source, the
+// default ttl for the sources not cached, and returns the number of files removed
+func (c *Cache) Prune() (int, error) {
+	sources, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	for _, source := range sources {
+		if !source.IsDir() {
+			continue
+		}
+		ttl := c.ttl(source.Name())
+		if ttl <= 0 {
+			ttl = c.TTL
+		}
+		dir := filepath.Join(c.dir, source.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return removed, err
+		}
+		for _, file := range files {
+			// the temporary files left by interrupted runs are removed as well
+			info, err := file.Info()
+			if err != nil || file.IsDir() || time.Since(info.ModTime()) <= ttl {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, file.Name())); err == nil {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// CacheWriter writes the urls of a source to the cache as they are found,
+// they replace the cached ones once committed
+type CacheWriter struct {
+	file    string
+	temp    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	err     error
+}
+
+// Writer returns a writer replacing the cached urls of the source for the key
+func (c *Cache) Writer(source, key string) (*CacheWriter, error) {
+	file := c.file(source, key)
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(file), key+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(temp)
+	return &CacheWriter{file: file, temp: temp, writer: writer, encoder: json.NewEncoder(writer)}, nil
+}
+
+// Write adds the url of the result to the cache
+func (w *CacheWriter) Write(result Result) {
+	if w.err == nil {
+		w.err = w.encoder.Encode(cachedResult{Value: result.Value, Metadata: result.Metadata})
+	}
+}
+
+// Commit replaces the cached urls by the ones written
+func (w *CacheWriter) Commit() error {
+	if w.err == nil {
+		w.err = w.writer.Flush()
+	}
+	if err := w.temp.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		os.Remove(w.temp.Name())
+		return w.err
+	}
+	return os.Rename(w.temp.Name(), w.file)
+}
+
+// Discard drops the urls written, the cached ones are kept
+func (w *CacheWriter) Discard() {
+	w.temp.Close()
+	os.Remove(w.temp.Name())
+}
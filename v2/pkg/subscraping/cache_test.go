@@ -0,0 +1,106 @@
+package subscraping
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeCache(t *testing.T, cache *Cache, source, key string, values ...string) {
+	writer, err := cache.Writer(source, key)
+	require.Nil(t, err)
+	for _, value := range values {
+		writer.Write(Result{Source: source, Type: URL, Value: value, Metadata: &Metadata{Hits: 1}})
+	}
+	require.Nil(t, writer.Commit())
+}
+
+func TestCacheLoad(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	key := CacheKey("webarchive", "example.com", &Session{})
+
+	_, ok := cache.Load("webarchive", key)
+	require.False(t, ok, "nothing is cached yet")
+
+	writeCache(t, cache, "webarchive", key, "https://example.com/a", "https://example.com/b")
+	results, ok := cache.Load("webarchive", key)
+	require.True(t, ok)
+	require.Equal(t, []Result{
+		{Source: "webarchive", Type: URL, Value: "https://example.com/a", Metadata: &Metadata{Hits: 1}},
+		{Source: "webarchive", Type: URL, Value: "https://example.com/b", Metadata: &Metadata{Hits: 1}},
+	}, results)
+
+	// A discarded writer keeps the cached results
+	writer, err := cache.Writer("webarchive", key)
+	require.Nil(t, err)
+	writer.Write(Result{Value: "https://example.com/c"})
+	writer.Discard()
+	results, ok = cache.Load("webarchive", key)
+	require.True(t, ok)
+	require.Len(t, results, 2)
+
+	cache.Refresh = true
+	_, ok = cache.Load("webarchive", key)
+	require.False(t, ok, "refreshing ignores the cached results")
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	cache.TTLs["sitemap"] = 0
+	cache.TTLs["alienvault"] = time.Hour
+	require.False(t, cache.Enabled("sitemap"))
+	require.True(t, cache.Enabled("webarchive"))
+
+	key := CacheKey("alienvault", "example.com", &Session{})
+	writeCache(t, cache, "alienvault", key, "https://example.com/a")
+	_, ok := cache.Load("alienvault", key)
+	require.True(t, ok)
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.Nil(t, os.Chtimes(cache.file("alienvault", key), old, old))
+	_, ok = cache.Load("alienvault", key)
+	require.False(t, ok, "the results are older than the ttl of the source")
+	_, err := os.Stat(cache.file("alienvault", key))
+	require.True(t, os.IsNotExist(err), "the expired results are removed")
+}
+
+func TestCachePrune(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	cache.TTLs["alienvault"] = time.Hour
+
+	removed, err := cache.Prune()
+	require.Nil(t, err)
+	require.Zero(t, removed, "nothing is cached yet")
+
+	old := time.Now().Add(-2 * time.Hour)
+	expired := CacheKey("alienvault", "example.com", &Session{})
+	writeCache(t, cache, "alienvault", expired, "https://example.com/a")
+	require.Nil(t, os.Chtimes(cache.file("alienvault", expired), old, old))
+	// The default ttl of webarchive is a day
+	fresh := CacheKey("webarchive", "example.com", &Session{})
+	writeCache(t, cache, "webarchive", fresh, "https://example.com/a")
+	require.Nil(t, os.Chtimes(cache.file("webarchive", fresh), old, old))
+
+	removed, err = cache.Prune()
+	require.Nil(t, err)
+	require.Equal(t, 1, removed)
+	_, ok := cache.Load("webarchive", fresh)
+	require.True(t, ok)
+}
+
+func TestCacheKey(t *testing.T) {
+	key := CacheKey("webarchive", "example.com", &Session{})
+	require.Equal(t, key, CacheKey("webarchive", "example.com", &Session{}))
+	require.NotEqual(t, key, CacheKey("webarchive", "example.org", &Session{}))
+	require.NotEqual(t, key, CacheKey("commoncrawl", "example.com", &Session{}))
+	require.NotEqual(t, key, CacheKey("webarchive", "example.com", &Session{MaxPages: 2}))
+	require.NotEqual(t, key, CacheKey("webarchive", "example.com", &Session{Dates: DateRange{Since: time.Now()}}))
+
+	// A relative range computed again on the same day keeps its key
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	since := CacheKey("webarchive", "example.com", &Session{Dates: DateRange{Since: day.Add(time.Hour)}})
+	require.Equal(t, since, CacheKey("webarchive", "example.com", &Session{Dates: DateRange{Since: day.Add(20 * time.Hour)}}))
+	require.NotEqual(t, since, CacheKey("webarchive", "example.com", &Session{Dates: DateRange{Since: day.Add(-time.Hour)}}))
+}
@@ -0,0 +1,64 @@
+package subscraping
+
+import "time"
+
+// DateRange restricts the results to the urls seen between two dates,
+// a zero bound leaves the range open on its side
+type DateRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// IsZero returns true if the range is unbounded
+func (d DateRange) IsZero() bool {
+	return d.Since.IsZero() && d.Until.IsZero()
+}
+
+// Contains returns true if the url was seen within the range. Urls
+// without dates are kept since nothing tells they are out of it.
+func (d DateRange) Contains(metadata *Metadata) bool {
+	if metadata == nil || metadata.FirstSeen.IsZero() {
+		return true
+	}
+	if !d.Since.IsZero() && metadata.LastSeen.Before(d.Since) {
+		return false
+	}
+	if !d.Until.IsZero() && metadata.FirstSeen.After(d.Until) {
+		return false
+	}
+	return true
+}
+
+// Years returns the years spanned by the range, zero for an open bound
+func (d DateRange) Years() (from, to int) {
+	if !d.Since.IsZero() {
+		from = d.Since.Year()
+	}
+	if !d.Until.IsZero() {
+		to = d.Until.Year()
+	}
+	return from, to
+}
+
+// Timestamps returns the bounds of the range in the timestamp layout of
+// the wayback machine and common crawl, empty for an open bound
+func (d DateRange) Timestamps() (from, to string) {
+	if !d.Since.IsZero() {
+		from = d.Since.UTC().Format(waybackTimestamp)
+	}
+	if !d.Until.IsZero() {
+		to = d.Until.UTC().Format(waybackTimestamp)
+	}
+	return from, to
+}
+
+// Days returns the bounds of the range rounded to the day, empty for an open bound
+func (d DateRange) Days() (from, to string) {
+	if !d.Since.IsZero() {
+		from = d.Since.UTC().Format("20060102")
+	}
+	if !d.Until.IsZero() {
+		to = d.Until.UTC().Format("20060102")
+	}
+	return from, to
+}
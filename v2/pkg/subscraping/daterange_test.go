@@ -0,0 +1,43 @@
+package subscraping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateRangeContains(t *testing.T) {
+	dates := DateRange{
+		Since: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC),
+	}
+	seen := func(first, last int) *Metadata {
+		return &Metadata{
+			FirstSeen: time.Date(first, 6, 1, 0, 0, 0, 0, time.UTC),
+			LastSeen:  time.Date(last, 6, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	require.True(t, dates.Contains(nil), "undated urls are kept")
+	require.True(t, dates.Contains(&Metadata{Mime: "text/html"}), "undated urls are kept")
+	require.True(t, dates.Contains(seen(2015, 2018)), "overlapping the start")
+	require.True(t, dates.Contains(seen(2019, 2022)), "overlapping the end")
+	require.True(t, dates.Contains(seen(2015, 2022)), "spanning the range")
+	require.False(t, dates.Contains(seen(2015, 2017)), "gone before the start")
+	require.False(t, dates.Contains(seen(2020, 2022)), "appeared after the end")
+	require.True(t, DateRange{}.Contains(seen(2015, 2017)))
+}
+
+func TestDateRangeBounds(t *testing.T) {
+	dates := DateRange{Since: time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)}
+	from, to := dates.Years()
+	require.Equal(t, 2018, from)
+	require.Zero(t, to)
+
+	since, until := dates.Timestamps()
+	require.Equal(t, "20180304050607", since)
+	require.Empty(t, until)
+	require.False(t, dates.IsZero())
+	require.True(t, DateRange{}.IsZero())
+}
@@ -0,0 +1,364 @@
+package subscraping
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// ErrNoHealthyKey is returned when every api key of a source is invalid or rate limited
+var ErrNoHealthyKey = errors.New("no healthy api key left")
+
+const (
+	// rateLimitCooldown is how long a key is rested after a 429 without Retry-After
+	rateLimitCooldown = time.Minute
+	// invalidKeyCooldown is how long a refused key is skipped by the next runs
+	invalidKeyCooldown = 24 * time.Hour
+)
+
+// KeyState is the health of an api key
+type KeyState int
+
+// States of an api key
+const (
+	KeyOK KeyState = iota
+	KeyRateLimited
+	KeyInvalid
+)
+
+// String returns the name of the state
+func (k KeyState) String() string {
+	switch k {
+	case KeyRateLimited:
+		return "rate-limited"
+	case KeyInvalid:
+		return "invalid"
+	default:
+		return "ok"
+	}
+}
+
+// KeyStatistics contains the usage of an api key
+type KeyStatistics struct {
+	Source   string
+	Key      string
+	State    KeyState
+	Until    time.Time
+	Requests int
+	Failures int
+}
+
+type keyEntry struct {
+	State    KeyState  `json:"state"`
+	Until    time.Time `json:"until"`
+	requests int
+	failures int
+}
+
+// KeyManager tracks the health of the api keys of the sources and rotates
+// to the next healthy key when a key is refused or rate limited.
+// A single manager is shared by every session of a run.
+type KeyManager struct {
+	mutex sync.Mutex
+	// keys holds the entries of each source by key
+	keys map[string]map[string]*keyEntry
+	// next is the index of the key tried first for each source
+	next map[string]int
+	// saved holds the cooldowns loaded from a previous run, by source and key hash
+	saved map[string]map[string]*keyEntry
+}
+
+// NewKeyManager creates a key manager with every key healthy
+func NewKeyManager() *KeyManager {
+	return &KeyManager{
+		keys: make(map[string]map[string]*keyEntry),
+		next: make(map[string]int),
+	}
+}
+
+// Do sends the request with the keys of the source in turn, starting with
+// the next healthy one, until a key is neither refused nor rate limited.
+// A key is refused by the status codes given, 401 and 403 by default.
+func (m *KeyManager) Do(source string, keys []string, request func(key string) (*http.Response, error), refused ...int) (*http.Response, error) {
+	if len(refused) == 0 {
+		refused = []int{http.StatusUnauthorized, http.StatusForbidden}
+	}
+
+	var lastErr error
+	for tried := 0; tried < len(keys); tried++ {
+		key, ok := m.pick(source, keys)
+		if !ok {
+			break
+		}
+
+		resp, err := request(key)
+		if !m.record(source, key, resp, refused) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoHealthyKey, lastErr)
+	}
+	return nil, ErrNoHealthyKey
+}
+
+// DoWithKeys sends the request with the keys of the source in turn like
+// KeyManager.Do. Each request is attempted once so that a rate limited key
+// is rotated right away, the retry policy of the context backs off only
+// once every key is rate limited, until the first one cools down.
+func (s *Session) DoWithKeys(ctx context.Context, source string, keys []string, request func(ctx context.Context, key string) (*http.Response, error), refused ...int) (*http.Response, error) {
+	policy := s.retryPolicy(ctx)
+	attemptCtx := WithoutRetry(ctx)
+	for attempt := 0; ; attempt++ {
+		resp, err := s.Keys.Do(source, keys, func(key string) (*http.Response, error) {
+			return request(attemptCtx, key)
+		}, refused...)
+		if attempt >= policy.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case errors.Is(err, ErrNoHealthyKey):
+			// Refused keys do not heal during the run
+			cooldown, ok := s.Keys.cooldown(source, keys)
+			if !ok {
+				return resp, err
+			}
+			wait = policy.delay(attempt, nil)
+			if cooldown > wait {
+				wait = cooldown
+			}
+		case shouldRetry(ctx, resp, err):
+			wait = policy.delay(attempt, resp)
+		default:
+			return resp, err
+		}
+
+		// Give up right away if the enumeration ends before the retry
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+		s.DiscardHTTPResponse(resp)
+		atomic.AddInt32(&s.retries, 1)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// cooldown returns how long until a key of the source is healthy again,
+// false if every key is refused
+func (m *KeyManager) cooldown(source string, keys []string) (time.Duration, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	found := false
+	for _, key := range keys {
+		entry := m.entry(source, key)
+		switch {
+		case entry.State == KeyOK || !now.Before(entry.Until):
+			return 0, true
+		case entry.State == KeyRateLimited:
+			if until := entry.Until.Sub(now); !found || until < wait {
+				wait = until
+			}
+			found = true
+		}
+	}
+	return wait, found
+}
+
+// pick returns the next healthy key of the source in round robin order
+func (m *KeyManager) pick(source string, keys []string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(keys); i++ {
+		index := (m.next[source] + i) % len(keys)
+		entry := m.entry(source, keys[index])
+		if entry.State != KeyOK && now.Before(entry.Until) {
+			continue
+		}
+		entry.State = KeyOK
+		m.next[source] = index + 1
+		return keys[index], true
+	}
+	return "", false
+}
+
+// record updates the health of the key from the response of the
+// request and returns true if another key should be tried
+func (m *KeyManager) record(source, key string, resp *http.Response, refused []int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := m.entry(source, key)
+	entry.requests++
+	if resp == nil {
+		return false
+	}
+
+	switch {
+	case slices.Contains(refused, resp.StatusCode):
+		entry.State = KeyInvalid
+		entry.Until = time.Now().Add(invalidKeyCooldown)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		cooldown, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			cooldown = rateLimitCooldown
+		}
+		entry.State = KeyRateLimited
+		entry.Until = time.Now().Add(cooldown)
+	default:
+		return false
+	}
+	entry.failures++
+	return true
+}
+
+// entry returns the entry of the key, creating it if needed
+func (m *KeyManager) entry(source, key string) *keyEntry {
+	entries, ok := m.keys[source]
+	if !ok {
+		entries = make(map[string]*keyEntry)
+		m.keys[source] = entries
+	}
+	entry, ok := entries[key]
+	if !ok {
+		entry = &keyEntry{}
+		if saved, ok := m.saved[source][hashKey(key)]; ok && time.Now().Before(saved.Until) {
+			entry.State, entry.Until = saved.State, saved.Until
+		}
+		entries[key] = entry
+	}
+	return entry
+}
+
+// Statistics returns the usage of the keys used during the run, by source and key
+func (m *KeyManager) Statistics() []KeyStatistics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var stats []KeyStatistics
+	for source, entries := range m.keys {
+		for key, entry := range entries {
+			if entry.requests == 0 {
+				continue
+			}
+			stats = append(stats, KeyStatistics{
+				Source:   source,
+				Key:      key,
+				State:    entry.State,
+				Until:    entry.Until,
+				Requests: entry.requests,
+				Failures: entry.failures,
+			})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Source != stats[j].Source {
+			return stats[i].Source < stats[j].Source
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// Load reads the cooldowns saved by a previous run, they are
+// applied to the keys matching their hash when first used
+func (m *KeyManager) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	saved := map[string]map[string]*keyEntry{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.saved = saved
+	m.mutex.Unlock()
+	return nil
+}
+
+// HasState returns true if the manager used keys or loaded the cooldowns
+// of a previous run, a run without keys has no state to save
+func (m *KeyManager) HasState() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.keys) > 0 || len(m.saved) > 0
+}
+
+// Save writes the cooldowns still running so that the next runs skip
+// the keys, the keys themselves are not written but their hash
+func (m *KeyManager) Save(file string) error {
+	m.mutex.Lock()
+	saved := map[string]map[string]*keyEntry{}
+	now := time.Now()
+	// Keep the cooldowns of the keys left unused by this run
+	for source, entries := range m.saved {
+		for hash, entry := range entries {
+			if now.Before(entry.Until) {
+				if saved[source] == nil {
+					saved[source] = make(map[string]*keyEntry)
+				}
+				saved[source][hash] = entry
+			}
+		}
+	}
+	for source, entries := range m.keys {
+		for key, entry := range entries {
+			if entry.State == KeyOK || !now.Before(entry.Until) {
+				delete(saved[source], hashKey(key))
+				continue
+			}
+			if saved[source] == nil {
+				saved[source] = make(map[string]*keyEntry)
+			}
+			saved[source][hashKey(key)] = &keyEntry{State: entry.State, Until: entry.Until}
+		}
+	}
+	m.mutex.Unlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// MaskKey hides the api key but for its first and last characters
+func MaskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
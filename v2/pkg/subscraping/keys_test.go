@@ -0,0 +1,128 @@
+package subscraping
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keyServer answers with the status code given for each key
+func keyServer(t *testing.T, statusCodes map[string]int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if statusCode, ok := statusCodes[r.Header.Get("X-Key")]; ok {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(statusCode)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("X-Key")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func keyRequest(t *testing.T, session *Session, server *httptest.Server) func(key string) (*http.Response, error) {
+	return func(key string) (*http.Response, error) {
+		return session.Get(WithoutRetry(context.Background()), server.URL, "", map[string]string{"X-Key": key})
+	}
+}
+
+func TestKeyManagerRotation(t *testing.T) {
+	server := keyServer(t, map[string]int{"invalid": http.StatusUnauthorized, "limited": http.StatusTooManyRequests})
+	session := newTestSession(t)
+	keys := NewKeyManager()
+	request := keyRequest(t, session, server)
+
+	resp, err := keys.Do("test", []string{"invalid", "limited", "good"}, request)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	// The refused keys are not tried again
+	resp, err = keys.Do("test", []string{"invalid", "limited", "good"}, request)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	stats := keys.Statistics()
+	require.Len(t, stats, 3)
+	require.Equal(t, KeyStatistics{Source: "test", Key: "good", State: KeyOK, Requests: 2}, stats[0])
+	require.Equal(t, KeyInvalid, stats[1].State)
+	require.Equal(t, 1, stats[1].Requests)
+	require.Equal(t, KeyRateLimited, stats[2].State)
+	require.Equal(t, 1, stats[2].Failures)
+
+	_, err = keys.Do("test", []string{"invalid", "limited"}, request)
+	require.ErrorIs(t, err, ErrNoHealthyKey)
+}
+
+func TestKeyManagerRoundRobin(t *testing.T) {
+	server := keyServer(t, nil)
+	session := newTestSession(t)
+	keys := NewKeyManager()
+
+	var used []string
+	for i := 0; i < 4; i++ {
+		resp, err := keys.Do("test", []string{"a", "b"}, keyRequest(t, session, server))
+		require.Nil(t, err)
+		resp.Body.Close()
+		used = append(used, resp.Request.Header.Get("X-Key"))
+	}
+	require.Equal(t, []string{"a", "b", "a", "b"}, used)
+}
+
+func TestDoWithKeys(t *testing.T) {
+	server := keyServer(t, map[string]int{"invalid": http.StatusUnauthorized, "limited": http.StatusTooManyRequests})
+	session := newTestSession(t)
+
+	// A rate limited key is rotated right away instead of being retried
+	var used []string
+	request := func(ctx context.Context, key string) (*http.Response, error) {
+		used = append(used, key)
+		return session.Get(ctx, server.URL, "", map[string]string{"X-Key": key})
+	}
+	resp, err := session.DoWithKeys(context.Background(), "test", []string{"limited", "good"}, request)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, []string{"limited", "good"}, used)
+	require.Zero(t, session.Retries())
+
+	// Refused keys are not waited for
+	_, err = session.DoWithKeys(context.Background(), "test", []string{"invalid"}, request)
+	require.ErrorIs(t, err, ErrNoHealthyKey)
+	require.Zero(t, session.Retries())
+
+	// The cooldown of the rate limited keys outlasts the enumeration
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = session.DoWithKeys(ctx, "test", []string{"limited"}, request)
+	require.ErrorIs(t, err, ErrNoHealthyKey)
+	require.Zero(t, session.Retries())
+}
+
+func TestKeyManagerPersistence(t *testing.T) {
+	server := keyServer(t, map[string]int{"invalid": http.StatusForbidden})
+	session := newTestSession(t)
+	file := filepath.Join(t.TempDir(), "key-state.json")
+
+	keys := NewKeyManager()
+	_, err := keys.Do("test", []string{"invalid"}, keyRequest(t, session, server))
+	require.ErrorIs(t, err, ErrNoHealthyKey)
+	require.Nil(t, keys.Save(file))
+
+	// The next run skips the key without sending a request
+	keys = NewKeyManager()
+	require.Nil(t, keys.Load(file))
+	_, err = keys.Do("test", []string{"invalid"}, func(key string) (*http.Response, error) {
+		t.Fatal("the invalid key should not be used")
+		return nil, nil
+	})
+	require.ErrorIs(t, err, ErrNoHealthyKey)
+}
+
+func TestMaskKey(t *testing.T) {
+	require.Equal(t, "0e1f****4a3f", MaskKey("0e1f8d4a3b2c9e7f6a5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f"))
+	require.Equal(t, "****", MaskKey("short"))
+}
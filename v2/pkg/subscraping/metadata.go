@@ -0,0 +1,90 @@
+package subscraping
+
+import (
+	"strconv"
+	"time"
+)
+
+// waybackTimestamp is the layout of the timestamps of the wayback machine and common crawl
+const waybackTimestamp = "20060102150405"
+
+// Metadata is what a source knows about an url besides the url
+// itself, the zero value of a field means that it is unknown
+type Metadata struct {
+	// FirstSeen and LastSeen are the dates of the first and last sighting of the url
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// StatusCode is the http status code archived or reported for the url
+	StatusCode int
+	// Mime is the content type archived or reported for the url
+	Mime string
+	// Hits is the number of captures or sightings of the url
+	Hits int
+	// Extra holds source specific details, e.g. the hostname reported by alienvault
+	Extra map[string]string
+}
+
+// Seen records a sighting of the url at the given date
+func (m *Metadata) Seen(date time.Time) {
+	if date.IsZero() {
+		return
+	}
+	if m.FirstSeen.IsZero() || date.Before(m.FirstSeen) {
+		m.FirstSeen = date
+	}
+	if m.LastSeen.IsZero() || date.After(m.LastSeen) {
+		m.LastSeen = date
+	}
+}
+
+// Merge adds the metadata found by another source. The status code and
+// mime of the most recent sighting are kept, the hits are added up.
+func (m *Metadata) Merge(other *Metadata) {
+	if other == nil {
+		return
+	}
+
+	newer := m.LastSeen.IsZero() || other.LastSeen.After(m.LastSeen)
+	if other.StatusCode != 0 && (m.StatusCode == 0 || newer) {
+		m.StatusCode = other.StatusCode
+	}
+	if other.Mime != "" && (m.Mime == "" || newer) {
+		m.Mime = other.Mime
+	}
+	m.Seen(other.FirstSeen)
+	m.Seen(other.LastSeen)
+	m.Hits += other.Hits
+
+	for key, value := range other.Extra {
+		if _, ok := m.Extra[key]; ok || value == "" {
+			continue
+		}
+		if m.Extra == nil {
+			m.Extra = make(map[string]string)
+		}
+		m.Extra[key] = value
+	}
+}
+
+// ParseTime parses a date in the first of the layouts matching it,
+// the wayback timestamp layout is tried when none is given
+func ParseTime(value string, layouts ...string) time.Time {
+	if len(layouts) == 0 {
+		layouts = []string{waybackTimestamp}
+	}
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// ParseStatusCode parses a status code, returning zero for anything but a valid code
+func ParseStatusCode(value string) int {
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 100 || code > 599 {
+		return 0
+	}
+	return code
+}
@@ -0,0 +1,64 @@
+package subscraping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataSeen(t *testing.T) {
+	var metadata Metadata
+	metadata.Seen(time.Time{})
+	require.True(t, metadata.FirstSeen.IsZero())
+
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	metadata.Seen(last)
+	metadata.Seen(first)
+	metadata.Seen(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, first, metadata.FirstSeen)
+	require.Equal(t, last, metadata.LastSeen)
+}
+
+func TestMetadataMerge(t *testing.T) {
+	metadata := &Metadata{
+		FirstSeen:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode: 200,
+		Mime:       "text/html",
+		Hits:       3,
+		Extra:      map[string]string{"hostname": "example.com"},
+	}
+	metadata.Merge(nil)
+	metadata.Merge(&Metadata{
+		FirstSeen:  time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode: 404,
+		Hits:       2,
+		Extra:      map[string]string{"hostname": "www.example.com", "uniqcount": "1"},
+	})
+	// An older sighting does not override the status code
+	metadata.Merge(&Metadata{LastSeen: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), StatusCode: 500})
+
+	require.Equal(t, &Metadata{
+		FirstSeen:  time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode: 404,
+		Mime:       "text/html",
+		Hits:       5,
+		Extra:      map[string]string{"hostname": "example.com", "uniqcount": "1"},
+	}, metadata)
+}
+
+func TestParseTime(t *testing.T) {
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), ParseTime("20200102030405"))
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), ParseTime("2020-01-02T03:04:05Z", "2006-01-02", time.RFC3339))
+	require.True(t, ParseTime("-").IsZero())
+}
+
+func TestParseStatusCode(t *testing.T) {
+	require.Equal(t, 301, ParseStatusCode("301"))
+	require.Zero(t, ParseStatusCode("-"))
+	require.Zero(t, ParseStatusCode("42"))
+}
@@ -0,0 +1,39 @@
+package subscraping
+
+import "context"
+
+// Progress is how far the sources went through the results of a domain,
+// an interrupted enumeration resumes from it
+type Progress struct {
+	// Cursors holds the position of the next page of each source
+	Cursors map[string]string `json:"cursors,omitempty"`
+	// Done holds the sources which returned all of their results
+	Done map[string]bool `json:"done,omitempty"`
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose enumeration records its progress and
+// resumes from the given one, the sources already done are skipped and the
+// others continue from their cursor
+func WithProgress(ctx context.Context, progress Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// ProgressFrom returns the progress the enumeration of the context resumes from
+func ProgressFrom(ctx context.Context) Progress {
+	progress, _ := ctx.Value(progressKey{}).(Progress)
+	return progress
+}
+
+// Resumable returns true if the enumeration of the context records its
+// progress, the sources then return Cursor results and the agent Done ones
+func Resumable(ctx context.Context) bool {
+	_, ok := ctx.Value(progressKey{}).(Progress)
+	return ok
+}
+
+// ResumeCursor returns the position the source resumes from, empty to start over
+func ResumeCursor(ctx context.Context, source string) string {
+	return ProgressFrom(ctx).Cursors[source]
+}
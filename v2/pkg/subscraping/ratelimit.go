@@ -0,0 +1,214 @@
+package subscraping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDailyQuota is returned when the daily request quota of a source is exhausted
+var ErrDailyQuota = errors.New("daily request quota exhausted")
+
+// RateLimit is the request budget of a source, zero fields are unlimited
+type RateLimit struct {
+	// PerSecond is the number of requests sent per second, it may be
+	// below one for sources counting their quota per minute
+	PerSecond float64 `yaml:"per-second,omitempty"`
+	// PerDay is the number of requests sent per day. It is counted by the
+	// run only, the requests of the previous runs are not remembered.
+	PerDay int `yaml:"per-day,omitempty"`
+	// MaxInFlight is the number of requests awaiting a response at the same time
+	MaxInFlight int `yaml:"max-in-flight,omitempty"`
+}
+
+// RateLimited is implemented by the sources declaring the default
+// rate limit of the service they query
+type RateLimited interface {
+	RateLimit() RateLimit
+}
+
+// Merge returns the rate limit with the fields set in override replacing its own
+func (r RateLimit) Merge(override RateLimit) RateLimit {
+	if override.PerSecond > 0 {
+		r.PerSecond = override.PerSecond
+	}
+	if override.PerDay > 0 {
+		r.PerDay = override.PerDay
+	}
+	if override.MaxInFlight > 0 {
+		r.MaxInFlight = override.MaxInFlight
+	}
+	return r
+}
+
+// String returns the rate limit in a human readable form
+func (r RateLimit) String() string {
+	var parts []string
+	if r.PerSecond > 0 {
+		parts = append(parts, strconv.FormatFloat(r.PerSecond, 'f', -1, 64)+"/s")
+	}
+	if r.PerDay > 0 {
+		parts = append(parts, strconv.Itoa(r.PerDay)+"/day")
+	}
+	if r.MaxInFlight > 0 {
+		parts = append(parts, strconv.Itoa(r.MaxInFlight)+" in-flight")
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseRateLimit parses a source=N value, N being the number of requests per second
+func ParseRateLimit(value string) (string, RateLimit, error) {
+	source, rate, ok := strings.Cut(value, "=")
+	source = strings.ToLower(strings.TrimSpace(source))
+	if !ok || source == "" {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit %q, expected source=N", value)
+	}
+	perSecond, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || perSecond <= 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit %q, expected a positive number of requests per second", value)
+	}
+	return source, RateLimit{PerSecond: perSecond}, nil
+}
+
+// Limiter enforces a rate limit, a single limiter is shared by
+// every session of a source so the limit holds across domains
+type Limiter struct {
+	limit RateLimit
+	// parent is the limiter enforcing the global rate limit on top of this one, if any
+	parent *Limiter
+	// interval is the time between two requests, zero is unlimited
+	interval time.Duration
+	inFlight chan struct{}
+
+	mutex sync.Mutex
+	// next is when the next request may be sent
+	next  time.Time
+	day   string
+	count int
+}
+
+// NewLimiter creates a limiter enforcing the rate limit
+func NewLimiter(limit RateLimit) *Limiter {
+	limiter := &Limiter{limit: limit}
+	if limit.PerSecond > 0 {
+		// Release a request every 1/rate seconds, fractional rates included
+		limiter.interval = time.Duration(float64(time.Second) / limit.PerSecond)
+	}
+	if limit.MaxInFlight > 0 {
+		limiter.inFlight = make(chan struct{}, limit.MaxInFlight)
+	}
+	return limiter
+}
+
+// NewChildLimiter creates a limiter enforcing the rate limit, its requests
+// being counted by the parent limiter as well
+func NewChildLimiter(limit RateLimit, parent *Limiter) *Limiter {
+	limiter := NewLimiter(limit)
+	limiter.parent = parent
+	return limiter
+}
+
+// Limit returns the rate limit enforced by the limiter
+func (l *Limiter) Limit() RateLimit {
+	return l.limit
+}
+
+// Take blocks until a request may be sent or the context is done. The
+// returned release function must be called once the response is received.
+func (l *Limiter) Take(ctx context.Context) (func(), error) {
+	// Fail fast once the quota is exhausted, the request is counted
+	// once it is about to be sent
+	if !l.countRequest(false) {
+		return nil, ErrDailyQuota
+	}
+
+	release := func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	if !l.countRequest(true) {
+		release()
+		return nil, ErrDailyQuota
+	}
+	if l.parent == nil {
+		return release, nil
+	}
+	releaseParent, err := l.parent.Take(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return func() {
+		releaseParent()
+		release()
+	}, nil
+}
+
+// wait blocks until the rate allows the next request or the context is done
+func (l *Limiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// countRequest returns false if the daily quota is exhausted,
+// otherwise the request is counted when asked
+func (l *Limiter) countRequest(count bool) bool {
+	if l.limit.PerDay <= 0 {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if l.day != today {
+		l.day, l.count = today, 0
+	}
+	if l.count >= l.limit.PerDay {
+		return false
+	}
+	if count {
+		l.count++
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package subscraping
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	source, limit, err := ParseRateLimit("VirusTotal=0.05")
+	require.Nil(t, err)
+	require.Equal(t, "virustotal", source)
+	require.Equal(t, RateLimit{PerSecond: 0.05}, limit)
+
+	for _, value := range []string{"virustotal", "=1", "urlscan=fast", "urlscan=0"} {
+		_, _, err := ParseRateLimit(value)
+		require.NotNil(t, err, value)
+	}
+}
+
+func TestRateLimitMerge(t *testing.T) {
+	limit := RateLimit{PerSecond: 2, PerDay: 1000}.Merge(RateLimit{PerSecond: 0.5, MaxInFlight: 1})
+	require.Equal(t, RateLimit{PerSecond: 0.5, PerDay: 1000, MaxInFlight: 1}, limit)
+	require.Equal(t, "0.5/s, 1000/day, 1 in-flight", limit.String())
+	require.Equal(t, "unlimited", RateLimit{}.String())
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	limiter := NewLimiter(RateLimit{PerDay: 2})
+	for i := 0; i < 2; i++ {
+		release, err := limiter.Take(context.Background())
+		require.Nil(t, err)
+		release()
+	}
+	_, err := limiter.Take(context.Background())
+	require.ErrorIs(t, err, ErrDailyQuota)
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	limiter := NewLimiter(RateLimit{MaxInFlight: 1})
+	release, err := limiter.Take(context.Background())
+	require.Nil(t, err)
+
+	// The second request waits for the first one to be released
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Take(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release, err = limiter.Take(context.Background())
+	require.Nil(t, err)
+	release()
+}
+
+func TestLimiterRateCancelled(t *testing.T) {
+	limiter := NewLimiter(RateLimit{PerSecond: 1.0 / 60, PerDay: 2})
+	release, err := limiter.Take(context.Background())
+	require.Nil(t, err)
+	release()
+
+	// The next request waits a minute unless the context is done first,
+	// a cancelled wait does not count against the daily quota
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = limiter.Take(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, 1, limiter.count)
+}
+
+func TestLimiterInterval(t *testing.T) {
+	for rate, interval := range map[float64]time.Duration{
+		0.05: 20 * time.Second,
+		1.5:  time.Second * 2 / 3,
+		2.5:  400 * time.Millisecond,
+		10:   100 * time.Millisecond,
+		0:    0,
+	} {
+		require.Equal(t, interval, NewLimiter(RateLimit{PerSecond: rate}).interval, "rate %v", rate)
+	}
+}
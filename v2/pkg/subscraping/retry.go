@@ -0,0 +1,114 @@
+package subscraping
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Network errors,
+// 429 and 5xx responses are retried with a jittered exponential backoff.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, zero disables retries
+	MaxRetries int
+	// BaseDelay is the wait before the first retry, doubled on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, a Retry-After header may ask for a longer wait
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of new sessions
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a context whose requests are retried
+// following the policy instead of the session one
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// WithoutRetry returns a context whose requests are attempted only once
+func WithoutRetry(ctx context.Context) context.Context {
+	return WithRetryPolicy(ctx, RetryPolicy{})
+}
+
+// retryPolicy returns the retry policy of the request context, or the session one
+func (s *Session) retryPolicy(ctx context.Context) RetryPolicy {
+	if policy, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return policy
+	}
+	return s.Retry
+}
+
+// delay returns the wait before the retry following the given attempt,
+// the Retry-After header of the response takes precedence over the backoff
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait
+		}
+	}
+
+	wait := p.BaseDelay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || wait < p.MaxDelay); i++ {
+		wait *= 2
+	}
+	if p.MaxDelay > 0 && wait > p.MaxDelay {
+		wait = p.MaxDelay
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// Wait between half and all of the backoff so that concurrent
+	// requests failing together do not retry together
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// parseRetryAfter parses a Retry-After header holding either seconds or a date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// shouldRetry returns true if the request failed in a way that may not happen again
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp != nil {
+		return resp.StatusCode == http.StatusTooManyRequests ||
+			(resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented)
+	}
+	if err == nil || errors.Is(err, ErrDailyQuota) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,104 @@
+package subscraping
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer fails the first requests with the status code then answers with the request body
+func flakyServer(t *testing.T, failures int32, statusCode int, header http.Header) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statusCode)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestSession(t *testing.T) *Session {
+	session, err := NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+	session.Retry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	return session
+}
+
+func TestHTTPRequestRetries(t *testing.T) {
+	server, requests := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	session := newTestSession(t)
+
+	resp, err := session.SimplePost(context.Background(), server.URL, "text/plain", strings.NewReader("payload"))
+	require.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	require.Equal(t, "payload", string(body), "the body should be sent again on retries")
+	require.Equal(t, int32(3), atomic.LoadInt32(requests))
+	require.Equal(t, 2, session.Retries())
+}
+
+func TestHTTPRequestGivesUp(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusTooManyRequests, nil)
+	session := newTestSession(t)
+
+	resp, err := session.SimpleGet(context.Background(), server.URL)
+	require.NotNil(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(4), atomic.LoadInt32(requests))
+}
+
+func TestHTTPRequestNotRetried(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusNotFound, nil)
+	session := newTestSession(t)
+	resp, err := session.SimpleGet(context.Background(), server.URL)
+	require.NotNil(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), atomic.LoadInt32(requests), "client errors should not be retried")
+
+	server, requests = flakyServer(t, 1, http.StatusBadGateway, nil)
+	resp, err = session.SimpleGet(WithoutRetry(context.Background()), server.URL)
+	require.NotNil(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), atomic.LoadInt32(requests), "the request opted out of retries")
+}
+
+func TestHTTPRequestRetryAfter(t *testing.T) {
+	server, requests := flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"3600"}})
+	session := newTestSession(t)
+
+	// The enumeration ends before the retry would be sent
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := session.SimpleGet(ctx, server.URL)
+	require.NotNil(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), atomic.LoadInt32(requests))
+	require.Zero(t, session.Retries())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		delay := policy.delay(attempt, nil)
+		require.True(t, delay >= max/2 && delay <= max, "attempt %d waited %s", attempt, delay)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"120"}}}
+	require.Equal(t, 2*time.Minute, policy.delay(0, resp))
+}
@@ -0,0 +1,61 @@
+package subscraping
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Robots contains the entries of a robots.txt file that point at urls
+type Robots struct {
+	// Paths are the Allow and Disallow paths, cut at the first wildcard
+	Paths []string
+	// Sitemaps are the values of the Sitemap directives
+	Sitemaps []string
+}
+
+// ParseRobots extracts the Allow/Disallow paths and Sitemap directives
+// of a robots.txt file. Duplicate entries are only returned once.
+func ParseRobots(reader io.Reader) Robots {
+	var robots Robots
+	seen := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		directive, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "allow", "disallow":
+			// wildcards and end anchors can't be turned into urls, keep the literal prefix
+			if i := strings.IndexAny(value, "*$"); i >= 0 {
+				value = value[:i]
+			}
+			if value == "" || !strings.HasPrefix(value, "/") {
+				continue
+			}
+			if _, ok := seen[value]; ok {
+				continue
+			}
+			seen[value] = struct{}{}
+			robots.Paths = append(robots.Paths, value)
+		case "sitemap":
+			if value == "" {
+				continue
+			}
+			if _, ok := seen[value]; ok {
+				continue
+			}
+			seen[value] = struct{}{}
+			robots.Sitemaps = append(robots.Sitemaps, value)
+		}
+	}
+	return robots
+}
@@ -0,0 +1,21 @@
+package subscraping
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := ParseRobots(strings.NewReader(`User-agent: *
+Disallow: /admin/
+disallow: /admin/
+Allow: /search$
+Disallow: /*.php
+Disallow:
+Sitemap: https://example.com/sitemap.xml # main
+`))
+	require.Equal(t, []string{"/admin/", "/search", "/"}, robots.Paths)
+	require.Equal(t, []string{"https://example.com/sitemap.xml"}, robots.Sitemaps)
+}
@@ -3,22 +3,48 @@ package alienvault
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
+const (
+	defaultBaseURL  = "https://otx.alienvault.com"
+	defaultPageSize = 1000
+)
+
 type alienvaultResponse struct {
-	Detail  string `json:"detail"`
-	Error   string `json:"error"`
-	UrlList []struct {
-		Url string `json:"url"`
+	Detail   string `json:"detail"`
+	Error    string `json:"error"`
+	HasNext  bool   `json:"has_next"`
+	FullSize int    `json:"full_size"`
+	UrlList  []struct {
+		Url      string `json:"url"`
+		Date     string `json:"date"`
+		Hostname string `json:"hostname"`
+		HttpCode int    `json:"httpcode"`
 	} `json:"url_list"`
 }
 
+// err returns the error reported by otx in the detail and error fields
+func (r *alienvaultResponse) err() error {
+	var messages []string
+	for _, message := range []string{r.Detail, r.Error} {
+		if message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
 // Source is the passive scraping agent
 type Source struct {
+	// BaseURL is the root of the otx api, overridden in tests
+	subscraping.BaseURL
 	timeTaken time.Duration
 	errors    int
 	results   int
@@ -36,37 +62,65 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		api := fmt.Sprintf("https://otx.alienvault.com/otxapi/indicators/domain/url_list/%s?limit=1000&page=1", domain)
-		//resp, err := session.Get(ctx, api, "", headers)
-		resp, err := session.SimpleGet(ctx, api)
-		if err != nil && resp == nil {
-			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
-			s.errors++
-			session.DiscardHTTPResponse(resp)
-			return
+		pageSize := session.PageSize
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
 		}
 
-		var response alienvaultResponse
-		// Get the response body and decode
-		err = json.NewDecoder(resp.Body).Decode(&response)
-		if err != nil {
-			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
-			s.errors++
-			resp.Body.Close()
-			return
+		first := 1
+		if cursor, err := strconv.Atoi(subscraping.ResumeCursor(ctx, s.Name())); err == nil && cursor > 1 {
+			first = cursor
 		}
-		resp.Body.Close()
+		for page := first; session.MaxPages <= 0 || page < first+session.MaxPages; page++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
 
-		if response.Error != "" {
-			results <- subscraping.Result{
-				Source: s.Name(), Type: subscraping.Error, Error: fmt.Errorf("%s, %s", response.Detail, response.Error),
+			api := fmt.Sprintf("%s/otxapi/indicators/domain/url_list/%s?limit=%d&page=%d", s.GetBaseURL(defaultBaseURL), domain, pageSize, page)
+			resp, err := session.SimpleGet(ctx, api)
+			if err != nil {
+				// A throttled or failed page ends the run, its error body is not a page
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				session.DiscardHTTPResponse(resp)
+				return
+			}
+
+			var response alienvaultResponse
+			// Get the response body and decode
+			err = json.NewDecoder(resp.Body).Decode(&response)
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				resp.Body.Close()
+				return
+			}
+			resp.Body.Close()
+
+			if response.Error != "" || response.Detail != "" {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: response.err()}
+				s.errors++
+				return
+			}
+
+			for _, record := range response.UrlList {
+				metadata := &subscraping.Metadata{StatusCode: record.HttpCode}
+				metadata.Seen(subscraping.ParseTime(record.Date, "2006-01-02T15:04:05", time.RFC3339))
+				if record.Hostname != "" {
+					metadata.Extra = map[string]string{"hostname": record.Hostname}
+				}
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: record.Url, Metadata: metadata}
+				s.results++
 			}
-			return
-		}
 
-		for _, record := range response.UrlList {
-			results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: record.Url}
-			s.results++
+			if !response.HasNext || len(response.UrlList) == 0 {
+				return
+			}
+			if subscraping.Resumable(ctx) {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Cursor, Value: strconv.Itoa(page + 1)}
+			}
 		}
 	}()
 
@@ -94,6 +148,11 @@ func (s *Source) NeedsKey() bool {
 	return true
 }
 
+// RateLimit keeps below the 10000 requests per hour allowed by otx
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 2}
+}
+
 func (s *Source) Statistics() subscraping.Statistics {
 	return subscraping.Statistics{
 		Errors:    s.errors,
@@ -0,0 +1,88 @@
+package alienvault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestRunPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/otxapi/indicators/domain/url_list/example.com", r.URL.Path)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		_, _ = fmt.Fprintf(w, `{"has_next": %t, "full_size": 3, "url_list": [{"url": "https://example.com/%d", "date": "2023-01-02T03:04:05", "hostname": "example.com", "httpcode": 200}]}`, page < 3, page)
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	var results []subscraping.Result
+	for result := range source.Run(context.Background(), "example.com", session) {
+		require.Equal(t, subscraping.URL, result.Type, "unexpected error: %v", result.Error)
+		results = append(results, result)
+	}
+
+	require.Len(t, results, 3)
+	require.Equal(t, "https://example.com/3", results[2].Value)
+	require.Equal(t, 200, results[0].Metadata.StatusCode)
+	require.Equal(t, "example.com", results[0].Metadata.Extra["hostname"])
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), results[0].Metadata.FirstSeen)
+}
+
+func TestRunThrottledPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"detail": "Request was throttled."}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"has_next": true, "url_list": [{"url": "https://example.com/1"}]}`)
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+	session.Retry = subscraping.RetryPolicy{}
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	var types []subscraping.ResultType
+	for result := range source.Run(context.Background(), "example.com", session) {
+		types = append(types, result.Type)
+	}
+
+	// The error keeps the agent from caching the partial results or marking the source done
+	require.Equal(t, []subscraping.ResultType{subscraping.URL, subscraping.Error}, types)
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestRunErrorDetail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"detail": "Authentication credentials were not provided."}`)
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	var results []subscraping.Result
+	for result := range source.Run(context.Background(), "example.com", session) {
+		results = append(results, result)
+	}
+	require.Len(t, results, 1)
+	require.Equal(t, subscraping.Error, results[0].Type)
+	require.EqualError(t, results[0].Error, "Authentication credentials were not provided.")
+}
@@ -35,25 +35,24 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
-		if randomApiKey == "" {
+		if len(s.apiKeys) == 0 {
 			s.skipped = true
 			return
 		}
 
 		getUrl := fmt.Sprintf("https://osint.bevigil.com/api/%s/urls/", domain)
 
-		resp, err := session.Get(ctx, getUrl, "", map[string]string{
-			"X-Access-Token": randomApiKey, "User-Agent": "urlfounder",
+		// A refused or rate limited key is rotated for the next one
+		resp, err := session.DoWithKeys(ctx, s.Name(), s.apiKeys, func(ctx context.Context, apiKey string) (*http.Response, error) {
+			return session.Get(ctx, getUrl, "", map[string]string{
+				"X-Access-Token": apiKey, "User-Agent": "urlfounder",
+			})
 		})
-		isForbidden := resp != nil && resp.StatusCode == http.StatusForbidden
 		if err != nil {
-			println(err.Error())
-			if !isForbidden {
-				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
-				session.DiscardHTTPResponse(resp)
-				return
-			}
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			session.DiscardHTTPResponse(resp)
+			return
 		}
 
 		var urls []string
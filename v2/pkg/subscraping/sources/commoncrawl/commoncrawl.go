@@ -0,0 +1,300 @@
+package commoncrawl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	defaultBaseURL    = "https://index.commoncrawl.org"
+	defaultMaxIndexes = 3
+	maxLineSize       = 1024 * 1024
+)
+
+type indexResponse struct {
+	ID     string `json:"id"`
+	APIURL string `json:"cdx-api"`
+}
+
+type numPagesResponse struct {
+	Pages int `json:"pages"`
+}
+
+type record struct {
+	URL       string `json:"url"`
+	Mime      string `json:"mime"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Source is the passive scraping agent
+type Source struct {
+	// BaseURL is the root of the common crawl index server, overridden in tests
+	subscraping.BaseURL
+	timeTaken time.Duration
+	errors    int
+	results   int
+}
+
+// Run function returns all urls found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		resp, err := session.SimpleGet(ctx, s.GetBaseURL(defaultBaseURL)+"/collinfo.json")
+		if err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			session.DiscardHTTPResponse(resp)
+			return
+		}
+
+		var indexes []indexResponse
+		err = jsoniter.NewDecoder(resp.Body).Decode(&indexes)
+		if err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			resp.Body.Close()
+			return
+		}
+		resp.Body.Close()
+
+		seen := make(map[string]struct{})
+		selected := selectIndexes(indexes, session)
+		// Resume from the index the previous run stopped at, if it is still queried
+		resumeIndex, resumePage := parseCursor(subscraping.ResumeCursor(ctx, s.Name()))
+		for i, index := range selected {
+			if index.ID == resumeIndex {
+				selected = selected[i:]
+				break
+			}
+		}
+		// The page limit applies to the source, across the indexes
+		remaining := session.MaxPages
+		for _, index := range selected {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			if session.MaxPages > 0 && remaining <= 0 {
+				return
+			}
+			firstPage := 0
+			if index.ID == resumeIndex {
+				firstPage = resumePage
+			}
+			fetched, err := s.enumerateIndex(ctx, index, firstPage, remaining, domain, session, seen, results)
+			// The next indexes are not read so that a resumed run starts over
+			// from the cursor of the failed one
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				return
+			}
+			remaining -= fetched
+		}
+	}()
+
+	return results
+}
+
+// selectIndexes returns the most recent indexes within the configured year
+// range and date range, collinfo.json lists the crawls newest first.
+func selectIndexes(indexes []indexResponse, session *subscraping.Session) []indexResponse {
+	maxIndexes := session.MaxIndexes
+	if maxIndexes <= 0 {
+		maxIndexes = defaultMaxIndexes
+	}
+
+	yearFrom, yearTo := session.Dates.Years()
+	var selected []indexResponse
+	for _, index := range indexes {
+		if len(selected) >= maxIndexes {
+			break
+		}
+		year := indexYear(index.ID)
+		if (session.YearFrom > 0 && year < session.YearFrom) || (yearFrom > 0 && year < yearFrom) {
+			continue
+		}
+		if (session.YearTo > 0 && year > session.YearTo) || (yearTo > 0 && year > yearTo) {
+			continue
+		}
+		selected = append(selected, index)
+	}
+	return selected
+}
+
+// indexYear extracts the year from an index id such as CC-MAIN-2023-50
+func indexYear(id string) int {
+	parts := strings.Split(id, "-")
+	if len(parts) < 3 {
+		return 0
+	}
+	year, _ := strconv.Atoi(parts[2])
+	return year
+}
+
+// parseCursor splits a cursor such as CC-MAIN-2023-50/3 into the index and its page
+func parseCursor(cursor string) (string, int) {
+	id, value, found := strings.Cut(cursor, "/")
+	if !found {
+		return "", 0
+	}
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 0 {
+		return "", 0
+	}
+	return id, page
+}
+
+// enumerateIndex reads the pages of the index from firstPage on, at most maxPages
+// of them unless it is zero, and returns the number of pages fetched
+func (s *Source) enumerateIndex(ctx context.Context, index indexResponse, firstPage, maxPages int, domain string, session *subscraping.Session, seen map[string]struct{}, results chan subscraping.Result) (int, error) {
+	apiURL := index.APIURL
+	query := url.Values{}
+	query.Set("url", "*."+domain)
+	query.Set("output", "json")
+	// the crawls spanning a bound of the range hold captures outside of it
+	from, to := session.Dates.Timestamps()
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+
+	pages, err := s.numPages(ctx, apiURL, query, session)
+	if err != nil {
+		return 0, err
+	}
+	if maxPages > 0 && pages > firstPage+maxPages {
+		pages = firstPage + maxPages
+	}
+
+	fetched := 0
+	for page := firstPage; page < pages; page++ {
+		select {
+		case <-ctx.Done():
+			return fetched, nil
+		default:
+		}
+
+		query.Set("page", strconv.Itoa(page))
+		resp, err := session.SimpleGet(ctx, apiURL+"?"+query.Encode())
+		fetched++
+		if err != nil {
+			session.DiscardHTTPResponse(resp)
+			// the index server answers 404 when an index holds no captures
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return fetched, nil
+			}
+			return fetched, err
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var r record
+			if err := jsoniter.UnmarshalFromString(line, &r); err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				continue
+			}
+			if _, ok := seen[r.URL]; ok || r.URL == "" {
+				continue
+			}
+			seen[r.URL] = struct{}{}
+			metadata := &subscraping.Metadata{StatusCode: subscraping.ParseStatusCode(r.Status), Mime: r.Mime}
+			metadata.Seen(subscraping.ParseTime(r.Timestamp))
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: r.URL, Metadata: metadata}
+			s.results++
+		}
+		resp.Body.Close()
+		// a page read partly is not done, a resumed run reads it again
+		if err := scanner.Err(); err != nil {
+			return fetched, err
+		}
+		if subscraping.Resumable(ctx) {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Cursor, Value: fmt.Sprintf("%s/%d", index.ID, page+1)}
+		}
+	}
+	return fetched, nil
+}
+
+// numPages queries the paged index api for the number of pages of the query
+func (s *Source) numPages(ctx context.Context, apiURL string, query url.Values, session *subscraping.Session) (int, error) {
+	pageQuery := url.Values{}
+	for key, values := range query {
+		pageQuery[key] = values
+	}
+	pageQuery.Set("showNumPages", "true")
+
+	resp, err := session.SimpleGet(ctx, apiURL+"?"+pageQuery.Encode())
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var response numPagesResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("could not decode page count from %s: %s", apiURL, err)
+	}
+	return response.Pages, nil
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return "commoncrawl"
+}
+
+func (s *Source) IsDefault() bool {
+	return false
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return false
+}
+
+func (s *Source) AddApiKeys(_ []string) {
+	// no key needed
+}
+
+func (s *Source) NeedsKey() bool {
+	return false
+}
+
+// RateLimit returns the budget asked by the index server, one request at a time
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 1, MaxInFlight: 1}
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+	}
+}
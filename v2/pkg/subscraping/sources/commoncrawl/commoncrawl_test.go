@@ -0,0 +1,134 @@
+package commoncrawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func TestRunIndexes(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/collinfo.json":
+			_, _ = fmt.Fprintf(w, `[{"id": "CC-MAIN-2023-50", "cdx-api": "%[1]s/CC-MAIN-2023-50-index"},
+				{"id": "CC-MAIN-2022-40", "cdx-api": "%[1]s/CC-MAIN-2022-40-index"},
+				{"id": "CC-MAIN-2021-10", "cdx-api": "%[1]s/CC-MAIN-2021-10-index"}]`, server.URL)
+		case "/CC-MAIN-2023-50-index", "/CC-MAIN-2022-40-index":
+			require.Equal(t, "*.example.com", query.Get("url"))
+			if query.Get("showNumPages") == "true" {
+				_, _ = fmt.Fprint(w, `{"pages": 2, "pageSize": 5, "blocks": 10}`)
+				return
+			}
+			// every index returns the same shared url to exercise deduplication
+			_, _ = fmt.Fprintf(w, "{\"url\": \"https://example.com/shared\"}\n{\"url\": \"https://example.com%s/%s\", \"mime\": \"text/html\"}\n", r.URL.Path, query.Get("page"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+	session.MaxIndexes = 2
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	var urls []string
+	for result := range source.Run(context.Background(), "example.com", session) {
+		require.Equal(t, subscraping.URL, result.Type, "unexpected error: %v", result.Error)
+		urls = append(urls, result.Value)
+	}
+	require.ElementsMatch(t, []string{
+		"https://example.com/shared",
+		"https://example.com/CC-MAIN-2023-50-index/0",
+		"https://example.com/CC-MAIN-2023-50-index/1",
+		"https://example.com/CC-MAIN-2022-40-index/0",
+		"https://example.com/CC-MAIN-2022-40-index/1",
+	}, urls)
+
+	// The page limit applies across the indexes
+	session.MaxPages = 3
+	urls = nil
+	for result := range source.Run(context.Background(), "example.com", session) {
+		require.Equal(t, subscraping.URL, result.Type, "unexpected error: %v", result.Error)
+		urls = append(urls, result.Value)
+	}
+	require.ElementsMatch(t, []string{
+		"https://example.com/shared",
+		"https://example.com/CC-MAIN-2023-50-index/0",
+		"https://example.com/CC-MAIN-2023-50-index/1",
+		"https://example.com/CC-MAIN-2022-40-index/0",
+	}, urls)
+}
+
+func TestRunReadError(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/collinfo.json":
+			_, _ = fmt.Fprintf(w, `[{"id": "CC-MAIN-2023-50", "cdx-api": "%[1]s/CC-MAIN-2023-50-index"},
+				{"id": "CC-MAIN-2022-40", "cdx-api": "%[1]s/CC-MAIN-2022-40-index"}]`, server.URL)
+		case "/CC-MAIN-2022-40-index":
+			// its cursors would replace the one of the failed index
+			t.Errorf("the index following a failed one should not be queried")
+		case "/CC-MAIN-2023-50-index":
+			if query.Get("showNumPages") == "true" {
+				_, _ = fmt.Fprint(w, `{"pages": 3, "pageSize": 5, "blocks": 15}`)
+				return
+			}
+			if query.Get("page") == "1" {
+				// a line longer than the scanner accepts stops the read of the page
+				_, _ = fmt.Fprintf(w, "{\"url\": \"https://example.com/%s\"}\n", strings.Repeat("a", maxLineSize))
+				return
+			}
+			_, _ = fmt.Fprintf(w, "{\"url\": \"https://example.com/%s\"}\n", query.Get("page"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	session, err := subscraping.NewSession("example.com", "", 0, 10)
+	require.Nil(t, err)
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	ctx := subscraping.WithProgress(context.Background(), subscraping.Progress{})
+	var urls, cursors []string
+	for result := range source.Run(ctx, "example.com", session) {
+		switch result.Type {
+		case subscraping.URL:
+			urls = append(urls, result.Value)
+		case subscraping.Cursor:
+			cursors = append(cursors, result.Value)
+		}
+	}
+	require.Equal(t, []string{"https://example.com/0"}, urls)
+	require.Equal(t, []string{"CC-MAIN-2023-50/1"}, cursors, "the page read partly must be read again")
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestSelectIndexes(t *testing.T) {
+	indexes := []indexResponse{{ID: "CC-MAIN-2023-50"}, {ID: "CC-MAIN-2022-40"}, {ID: "CC-MAIN-2021-10"}, {ID: "CC-MAIN-2020-05"}}
+
+	selected := selectIndexes(indexes, &subscraping.Session{MaxIndexes: 5, YearFrom: 2021, YearTo: 2022})
+	require.Equal(t, []indexResponse{{ID: "CC-MAIN-2022-40"}, {ID: "CC-MAIN-2021-10"}}, selected)
+
+	dates := subscraping.DateRange{Since: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	selected = selectIndexes(indexes, &subscraping.Session{MaxIndexes: 5, Dates: dates})
+	require.Equal(t, []indexResponse{{ID: "CC-MAIN-2023-50"}, {ID: "CC-MAIN-2022-40"}}, selected)
+
+	selected = selectIndexes(indexes, &subscraping.Session{})
+	require.Len(t, selected, defaultMaxIndexes)
+}
@@ -0,0 +1,271 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	// maxDepth is the maximum nesting of sitemap index files that is followed
+	maxDepth = 3
+	// maxBodySize caps the amount of data read from a single robots.txt or sitemap
+	maxBodySize = 10 * 1024 * 1024
+)
+
+var schemes = []string{"https", "http"}
+
+// errMissing is returned when the site answers that the file does not exist
+var errMissing = errors.New("file not found")
+
+// document matches both <urlset> and <sitemapindex> documents
+type document struct {
+	XMLName  xml.Name
+	URLs     []location `xml:"url"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc string `xml:"loc"`
+}
+
+// Source is the passive scraping agent
+type Source struct {
+	timeTaken time.Duration
+	errors    int
+	results   int
+}
+
+type run struct {
+	source  *Source
+	session *subscraping.Session
+	results chan subscraping.Result
+	seen    map[string]struct{}
+	visited map[string]struct{}
+}
+
+// Run function returns all urls found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		r := &run{
+			source:  s,
+			session: session,
+			results: results,
+			seen:    make(map[string]struct{}),
+			visited: make(map[string]struct{}),
+		}
+
+		base, body, err := r.fetchRobots(ctx, domain)
+		if err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			return
+		}
+		// Without robots.txt the sitemap is looked for at its usual place
+		if body == nil {
+			for _, scheme := range schemes {
+				if r.crawl(ctx, fmt.Sprintf("%s://%s/sitemap.xml", scheme, domain), 0) {
+					return
+				}
+			}
+			return
+		}
+
+		robots := subscraping.ParseRobots(bytes.NewReader(body))
+		for _, path := range robots.Paths {
+			r.emit(base + path)
+		}
+
+		sitemaps := robots.Sitemaps
+		if len(sitemaps) == 0 {
+			sitemaps = []string{base + "/sitemap.xml"}
+		}
+		for _, sitemap := range sitemaps {
+			r.crawl(ctx, sitemap, 0)
+		}
+	}()
+
+	return results
+}
+
+// fetchRobots requests robots.txt over each scheme and returns the first that
+// answers. The body is nil when the site answers that it has no robots.txt.
+func (r *run) fetchRobots(ctx context.Context, domain string) (string, []byte, error) {
+	var lastErr error
+	missing := false
+	for _, scheme := range schemes {
+		base := fmt.Sprintf("%s://%s", scheme, domain)
+		body, err := r.fetch(ctx, base+"/robots.txt")
+		if errors.Is(err, errMissing) {
+			missing = true
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return base, body, nil
+	}
+	if missing {
+		return "", nil, nil
+	}
+	return "", nil, lastErr
+}
+
+// crawl parses a sitemap and follows nested sitemap index files up to
+// maxDepth, it returns true if the sitemap was fetched
+func (r *run) crawl(ctx context.Context, sitemapURL string, depth int) bool {
+	if depth >= maxDepth || ctx.Err() != nil {
+		return false
+	}
+	if _, ok := r.visited[sitemapURL]; ok {
+		return false
+	}
+	r.visited[sitemapURL] = struct{}{}
+
+	body, err := r.fetch(ctx, sitemapURL)
+	if err != nil {
+		// sitemaps guessed or listed in robots.txt are frequently missing
+		return false
+	}
+
+	urls, nested := parseSitemap(body)
+	for _, value := range urls {
+		r.emit(value)
+	}
+	for _, value := range nested {
+		r.crawl(ctx, value, depth+1)
+	}
+	return true
+}
+
+func (r *run) fetch(ctx context.Context, target string) ([]byte, error) {
+	// The target is not an api, a robots.txt or sitemap failing
+	// once will fail again so it is not retried
+	resp, err := r.session.SimpleGet(subscraping.WithoutRetry(ctx), target)
+	if err != nil {
+		r.session.DiscardHTTPResponse(resp)
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: %s", errMissing, err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	return decompress(body), nil
+}
+
+func (r *run) emit(value string) {
+	if _, ok := r.seen[value]; ok {
+		return
+	}
+	r.seen[value] = struct{}{}
+	r.results <- subscraping.Result{Source: r.source.Name(), Type: subscraping.URL, Value: value}
+	r.source.results++
+}
+
+// decompress inflates gzip sitemaps, other content is returned as is
+func decompress(body []byte) []byte {
+	if len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b {
+		return body
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	defer reader.Close()
+
+	inflated, err := io.ReadAll(io.LimitReader(reader, maxBodySize))
+	if err != nil {
+		return body
+	}
+	return inflated
+}
+
+// parseSitemap returns the page urls and the nested sitemap urls of a xml
+// sitemap or sitemap index. Documents that are not xml are read as text
+// sitemaps holding one url per line.
+func parseSitemap(body []byte) ([]string, []string) {
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		var doc document
+		if err := xml.Unmarshal(trimmed, &doc); err != nil {
+			return nil, nil
+		}
+		var urls, nested []string
+		for _, u := range doc.URLs {
+			if loc := strings.TrimSpace(u.Loc); loc != "" {
+				urls = append(urls, loc)
+			}
+		}
+		for _, sitemap := range doc.Sitemaps {
+			if loc := strings.TrimSpace(sitemap.Loc); loc != "" {
+				nested = append(nested, loc)
+			}
+		}
+		return urls, nested
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if parsed, err := url.Parse(line); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+			urls = append(urls, line)
+		}
+	}
+	return urls, nil
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return "sitemap"
+}
+
+func (s *Source) IsDefault() bool {
+	return false
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return true
+}
+
+func (s *Source) AddApiKeys(_ []string) {
+	// no key needed
+}
+
+func (s *Source) NeedsKey() bool {
+	return false
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+	}
+}
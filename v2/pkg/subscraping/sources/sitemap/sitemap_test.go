@@ -0,0 +1,119 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/chainreactors/urlfounder/v2/pkg/testutils"
+)
+
+// runSource runs the source on the host of the server, which stands for the target itself
+func runSource(t *testing.T, source *Source, server *httptest.Server) []subscraping.Result {
+	domain := strings.TrimPrefix(server.URL, "http://")
+	return testutils.RunSource(context.Background(), source, domain, testutils.NewSession(t, domain))
+}
+
+func TestRunRobotsAndSitemaps(t *testing.T) {
+	var server *httptest.Server
+	server = testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			_, _ = fmt.Fprintf(w, "User-agent: *\nDisallow: /admin/ # private\nAllow: /api/*/public\nDisallow:\nSitemap: %s/sitemap_index.xml\n", server.URL)
+		case "/sitemap_index.xml":
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/posts.xml.gz</loc></sitemap>
+  <sitemap><loc>%[1]s/pages.txt</loc></sitemap>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+		case "/posts.xml.gz":
+			buffer := &bytes.Buffer{}
+			writer := gzip.NewWriter(buffer)
+			_, _ = fmt.Fprintf(writer, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/posts/1</loc></url></urlset>`, server.URL)
+			_ = writer.Close()
+			_, _ = w.Write(buffer.Bytes())
+		case "/pages.txt":
+			_, _ = fmt.Fprintf(w, "%[1]s/about\nnot a url\n%[1]s/contact\n", server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	urls := testutils.URLs(t, runSource(t, &Source{}, server))
+	require.Equal(t, []string{
+		server.URL + "/admin/",
+		server.URL + "/api/",
+		server.URL + "/posts/1",
+		server.URL + "/about",
+		server.URL + "/contact",
+	}, urls)
+}
+
+func TestRunWithoutRobots(t *testing.T) {
+	var server *httptest.Server
+	server = testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			_, _ = fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/about</loc></url></urlset>`, server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	source := &Source{}
+	require.Equal(t, []string{server.URL + "/about"}, testutils.URLs(t, runSource(t, source, server)))
+	require.Zero(t, source.Statistics().Errors, "a missing robots.txt is not an error")
+}
+
+func TestRunMaxDepth(t *testing.T) {
+	var server *httptest.Server
+	var requested []string
+	server = testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		// Each sitemap index nests the next one, down to /sitemap3.xml
+		next := map[string]string{"/sitemap.xml": "/sitemap1.xml", "/sitemap1.xml": "/sitemap2.xml", "/sitemap2.xml": "/sitemap3.xml"}
+		if nested, ok := next[r.URL.Path]; ok {
+			_, _ = fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s%s</loc></sitemap></sitemapindex>`, server.URL, nested)
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	runSource(t, &Source{}, server)
+	require.NotContains(t, requested, "/sitemap3.xml", "the sitemaps nested deeper than maxDepth are not followed")
+	require.Contains(t, requested, "/sitemap2.xml")
+}
+
+func TestRunRobotsServerError(t *testing.T) {
+	var requested []string
+	server := testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	source := &Source{}
+	results := runSource(t, source, server)
+	require.Len(t, results, 1)
+	require.Equal(t, subscraping.Error, results[0].Type)
+	require.Equal(t, 1, source.Statistics().Errors)
+	require.Equal(t, []string{"/robots.txt"}, requested, "the sitemap is not guessed when the site fails")
+}
+
+func TestParseSitemap(t *testing.T) {
+	urls, nested := parseSitemap([]byte(`<urlset><url><loc> https://example.com/a </loc></url></urlset>`))
+	require.Equal(t, []string{"https://example.com/a"}, urls)
+	require.Empty(t, nested)
+
+	urls, nested = parseSitemap([]byte(`<sitemapindex><sitemap><loc>https://example.com/s.xml</loc></sitemap></sitemapindex>`))
+	require.Empty(t, urls)
+	require.Equal(t, []string{"https://example.com/s.xml"}, nested)
+}
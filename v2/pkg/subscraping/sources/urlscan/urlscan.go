@@ -0,0 +1,218 @@
+package urlscan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	defaultBaseURL  = "https://urlscan.io"
+	defaultPageSize = 100
+)
+
+type response struct {
+	Results []struct {
+		Task struct {
+			URL  string `json:"url"`
+			Time string `json:"time"`
+		} `json:"task"`
+		Page struct {
+			URL      string `json:"url"`
+			Status   string `json:"status"`
+			MimeType string `json:"mimeType"`
+		} `json:"page"`
+		Sort []interface{} `json:"sort"`
+	} `json:"results"`
+	HasMore bool `json:"has_more"`
+}
+
+type quotasResponse struct {
+	Limits struct {
+		Search struct {
+			Day struct {
+				Limit     int `json:"limit"`
+				Remaining int `json:"remaining"`
+			} `json:"day"`
+		} `json:"search"`
+	} `json:"limits"`
+}
+
+// Source is the passive scraping agent
+type Source struct {
+	// BaseURL is the root of the urlscan api, overridden in tests
+	subscraping.BaseURL
+	apiKeys   []string
+	timeTaken time.Duration
+	errors    int
+	results   int
+	skipped   bool
+}
+
+// Run function returns all urls found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		if len(s.apiKeys) == 0 {
+			s.skipped = true
+			return
+		}
+
+		pageSize := session.PageSize
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
+		}
+
+		seen := make(map[string]struct{})
+		searchAfter := subscraping.ResumeCursor(ctx, s.Name())
+		for page := 0; session.MaxPages <= 0 || page < session.MaxPages; page++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			params := url.Values{}
+			params.Set("q", "domain:"+domain)
+			params.Set("size", strconv.Itoa(pageSize))
+			if searchAfter != "" {
+				params.Set("search_after", searchAfter)
+			}
+
+			resp, err := session.DoWithKeys(ctx, s.Name(), s.apiKeys, func(ctx context.Context, apiKey string) (*http.Response, error) {
+				return session.Get(ctx, fmt.Sprintf("%s/api/v1/search/?%s", s.GetBaseURL(defaultBaseURL), params.Encode()), "", map[string]string{
+					"API-Key": apiKey, "Accept": "application/json",
+				})
+			})
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				session.DiscardHTTPResponse(resp)
+				return
+			}
+
+			var data response
+			err = jsoniter.NewDecoder(resp.Body).Decode(&data)
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				resp.Body.Close()
+				return
+			}
+			resp.Body.Close()
+
+			for _, result := range data.Results {
+				metadata := &subscraping.Metadata{StatusCode: subscraping.ParseStatusCode(result.Page.Status), Mime: result.Page.MimeType}
+				metadata.Seen(subscraping.ParseTime(result.Task.Time, time.RFC3339))
+				for _, value := range []string{result.Page.URL, result.Task.URL} {
+					if _, ok := seen[value]; ok || value == "" {
+						continue
+					}
+					seen[value] = struct{}{}
+					results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: value, Metadata: metadata}
+					s.results++
+				}
+			}
+
+			if !data.HasMore || len(data.Results) == 0 {
+				return
+			}
+			searchAfter = sortKey(data.Results[len(data.Results)-1].Sort)
+			if searchAfter == "" {
+				return
+			}
+			if subscraping.Resumable(ctx) {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Cursor, Value: searchAfter}
+			}
+		}
+	}()
+
+	return results
+}
+
+// sortKey joins the sort values of the last hit into a search_after parameter
+func sortKey(values []interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case float64:
+			parts = append(parts, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ValidateKey reads the quotas of the key, which does not count against them
+func (s *Source) ValidateKey(ctx context.Context, key string, session *subscraping.Session) (subscraping.KeyProbe, error) {
+	resp, err := session.Get(subscraping.WithoutRetry(ctx), s.GetBaseURL(defaultBaseURL)+"/user/quotas/", "", map[string]string{
+		"API-Key": key, "Accept": "application/json",
+	})
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return subscraping.KeyProbe{}, nil
+		}
+		return subscraping.KeyProbe{}, err
+	}
+	defer resp.Body.Close()
+
+	var quotas quotasResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&quotas); err != nil {
+		return subscraping.KeyProbe{}, err
+	}
+	day := quotas.Limits.Search.Day
+	return subscraping.KeyProbe{Valid: true, Quota: fmt.Sprintf("%d/%d searches today", day.Remaining, day.Limit)}, nil
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return "urlscan"
+}
+
+func (s *Source) IsDefault() bool {
+	return true
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return false
+}
+
+func (s *Source) NeedsKey() bool {
+	return true
+}
+
+// RateLimit returns the search quota of the free plan
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 2, PerDay: 1000}
+}
+
+func (s *Source) AddApiKeys(keys []string) {
+	s.apiKeys = keys
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+		Skipped:   s.skipped,
+	}
+}
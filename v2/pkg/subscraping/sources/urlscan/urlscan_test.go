@@ -0,0 +1,112 @@
+package urlscan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/chainreactors/urlfounder/v2/pkg/testutils"
+)
+
+func TestRunSearchAfter(t *testing.T) {
+	server := testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/search/", r.URL.Path)
+		require.Equal(t, "domain:example.com", r.URL.Query().Get("q"))
+
+		switch r.URL.Query().Get("search_after") {
+		case "":
+			_, _ = fmt.Fprint(w, `{"has_more": true, "results": [
+				{"task": {"url": "https://example.com/"}, "page": {"url": "https://www.example.com/"}, "sort": [1690000000000, "a"]}]}`)
+		case "1690000000000,a":
+			_, _ = fmt.Fprint(w, `{"has_more": false, "results": [
+				{"task": {"url": "https://example.com/login"}, "page": {"url": "https://example.com/login", "status": "200"}, "sort": [1680000000000, "b"]}]}`)
+		default:
+			t.Errorf("unexpected search_after %q", r.URL.Query().Get("search_after"))
+		}
+	})
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	source.AddApiKeys([]string{"test-key"})
+
+	// The sort values of the last hit are the cursor of the next page
+	ctx := subscraping.WithProgress(context.Background(), subscraping.Progress{})
+	var urls, cursors []string
+	for _, result := range testutils.RunSource(ctx, source, "example.com", testutils.NewSession(t, "example.com")) {
+		switch result.Type {
+		case subscraping.URL:
+			urls = append(urls, result.Value)
+		case subscraping.Cursor:
+			cursors = append(cursors, result.Value)
+		default:
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+	}
+	require.Equal(t, []string{"https://www.example.com/", "https://example.com/", "https://example.com/login"}, urls)
+	require.Equal(t, []string{"1690000000000,a"}, cursors)
+
+	// A resumed run starts from the cursor
+	ctx = subscraping.WithProgress(context.Background(), subscraping.Progress{Cursors: map[string]string{"urlscan": "1690000000000,a"}})
+	results := testutils.RunSource(ctx, source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Equal(t, []string{"https://example.com/login"}, testutils.URLs(t, results))
+}
+
+func TestRunKeyRotation(t *testing.T) {
+	server := testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("API-Key") {
+		case "refused-key":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "throttled-key":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = fmt.Fprint(w, `{"has_more": false, "results": [{"page": {"url": "https://example.com/"}}]}`)
+		}
+	})
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	source.AddApiKeys([]string{"refused-key", "throttled-key", "test-key"})
+	session := testutils.NewSession(t, "example.com")
+	results := testutils.RunSource(context.Background(), source, "example.com", session)
+	require.Equal(t, []string{"https://example.com/"}, testutils.URLs(t, results))
+
+	// Without a healthy key left the source reports an error
+	source.AddApiKeys([]string{"refused-key", "throttled-key"})
+	results = testutils.RunSource(context.Background(), source, "example.com", session)
+	require.Len(t, results, 1)
+	require.ErrorIs(t, results[0].Error, subscraping.ErrNoHealthyKey)
+}
+
+func TestRunWithoutKey(t *testing.T) {
+	source := &Source{}
+	require.Empty(t, testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com")))
+	require.True(t, source.Statistics().Skipped)
+}
+
+func TestValidateKey(t *testing.T) {
+	server := testutils.NewSourceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/user/quotas/", r.URL.Path)
+		if r.Header.Get("API-Key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"limits": {"search": {"day": {"limit": 1000, "used": 10, "remaining": 990}}}}`)
+	})
+
+	source := &Source{}
+	source.SetBaseURL(server.URL)
+	session := testutils.NewSession(t, "")
+
+	probe, err := source.ValidateKey(context.Background(), "test-key", session)
+	require.Nil(t, err)
+	require.Equal(t, subscraping.KeyProbe{Valid: true, Quota: "990/1000 searches today"}, probe)
+
+	probe, err = source.ValidateKey(context.Background(), "wrong-key", session)
+	require.Nil(t, err)
+	require.False(t, probe.Valid)
+}
@@ -0,0 +1,297 @@
+package virustotal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	defaultBaseURL  = "https://www.virustotal.com"
+	defaultPageSize = 40
+	maxRetries      = 5
+	// defaultBackoff is the initial wait once every key is rate limited,
+	// the public api allows 4 requests per minute
+	defaultBackoff = 15 * time.Second
+	// maxBackoff caps the wait, the quota of the public api is counted per minute
+	maxBackoff = time.Minute
+	// scanDateLayout is the layout of the scan dates of the v2 domain report
+	scanDateLayout = "2006-01-02 15:04:05"
+)
+
+type urlsResponse struct {
+	Data []struct {
+		Attributes struct {
+			URL                         string `json:"url"`
+			LastHttpResponseCode        int    `json:"last_http_response_code"`
+			FirstSubmissionDate         int64  `json:"first_submission_date"`
+			LastAnalysisDate            int64  `json:"last_analysis_date"`
+			LastHttpResponseContentType string `json:"last_http_response_content_type"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Meta struct {
+		Cursor string `json:"cursor"`
+	} `json:"meta"`
+}
+
+type quotasResponse struct {
+	Data map[string]struct {
+		User struct {
+			Used    int `json:"used"`
+			Allowed int `json:"allowed"`
+		} `json:"user"`
+	} `json:"data"`
+}
+
+type domainReport struct {
+	DetectedUrls []struct {
+		URL      string `json:"url"`
+		ScanDate string `json:"scan_date"`
+	} `json:"detected_urls"`
+	UndetectedUrls [][]interface{} `json:"undetected_urls"`
+}
+
+// Source is the passive scraping agent
+type Source struct {
+	// BaseURL is the root of the virustotal api, overridden in tests
+	subscraping.BaseURL
+	// backoff is the initial wait once every key is rate limited, overridden in tests
+	backoff   time.Duration
+	apiKeys   []string
+	timeTaken time.Duration
+	errors    int
+	results   int
+	skipped   bool
+}
+
+// Run function returns all urls found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		if len(s.apiKeys) == 0 {
+			s.skipped = true
+			return
+		}
+
+		found, err := s.enumerateRelationships(ctx, domain, session, results)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		// the relationship endpoint is not available for every key, fall back to
+		// the v2 domain report unless the v3 api already returned urls
+		if found > 0 {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			return
+		}
+		if err := s.enumerateReport(ctx, domain, session, results); err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+		}
+	}()
+
+	return results
+}
+
+// enumerateRelationships follows the cursor of the v3 domain urls relationship
+func (s *Source) enumerateRelationships(ctx context.Context, domain string, session *subscraping.Session, results chan subscraping.Result) (int, error) {
+	pageSize := session.PageSize
+	if pageSize <= 0 || pageSize > defaultPageSize {
+		pageSize = defaultPageSize
+	}
+
+	found := 0
+	cursor := subscraping.ResumeCursor(ctx, s.Name())
+	for page := 0; session.MaxPages <= 0 || page < session.MaxPages; page++ {
+		params := url.Values{}
+		params.Set("limit", strconv.Itoa(pageSize))
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+
+		api := fmt.Sprintf("%s/api/v3/domains/%s/urls?%s", s.GetBaseURL(defaultBaseURL), domain, params.Encode())
+		// The relationship is forbidden to keys without access to it, only
+		// a 401 means that the key itself is wrong
+		resp, err := s.get(ctx, session, func(apiKey string) (string, map[string]string) {
+			return api, map[string]string{"x-apikey": apiKey}
+		}, http.StatusUnauthorized)
+		if err != nil {
+			return found, err
+		}
+
+		var data urlsResponse
+		err = jsoniter.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if err != nil {
+			return found, err
+		}
+
+		for _, item := range data.Data {
+			metadata := &subscraping.Metadata{
+				StatusCode: item.Attributes.LastHttpResponseCode,
+				Mime:       item.Attributes.LastHttpResponseContentType,
+			}
+			if item.Attributes.FirstSubmissionDate != 0 {
+				metadata.Seen(time.Unix(item.Attributes.FirstSubmissionDate, 0).UTC())
+			}
+			if item.Attributes.LastAnalysisDate != 0 {
+				metadata.Seen(time.Unix(item.Attributes.LastAnalysisDate, 0).UTC())
+			}
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: item.Attributes.URL, Metadata: metadata}
+			s.results++
+			found++
+		}
+
+		cursor = data.Meta.Cursor
+		if cursor == "" || len(data.Data) == 0 {
+			return found, nil
+		}
+		if subscraping.Resumable(ctx) {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Cursor, Value: cursor}
+		}
+	}
+	return found, nil
+}
+
+// enumerateReport reads the detected and undetected urls of the v2 domain report
+func (s *Source) enumerateReport(ctx context.Context, domain string, session *subscraping.Session, results chan subscraping.Result) error {
+	resp, err := s.get(ctx, session, func(apiKey string) (string, map[string]string) {
+		params := url.Values{}
+		params.Set("apikey", apiKey)
+		params.Set("domain", domain)
+		return fmt.Sprintf("%s/vtapi/v2/domain/report?%s", s.GetBaseURL(defaultBaseURL), params.Encode()), nil
+	}, http.StatusUnauthorized, http.StatusForbidden)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var report domainReport
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return err
+	}
+
+	for _, detected := range report.DetectedUrls {
+		metadata := &subscraping.Metadata{Extra: map[string]string{"detected": "true"}}
+		metadata.Seen(subscraping.ParseTime(detected.ScanDate, scanDateLayout))
+		results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: detected.URL, Metadata: metadata}
+		s.results++
+	}
+	// undetected urls are tuples of url, sha256, positives, total and scan date
+	for _, undetected := range report.UndetectedUrls {
+		if len(undetected) == 0 {
+			continue
+		}
+		value, ok := undetected[0].(string)
+		if !ok {
+			continue
+		}
+		result := subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: value}
+		if len(undetected) > 4 {
+			if date, ok := undetected[4].(string); ok {
+				result.Metadata = &subscraping.Metadata{}
+				result.Metadata.Seen(subscraping.ParseTime(date, scanDateLayout))
+			}
+		}
+		results <- result
+		s.results++
+	}
+	return nil
+}
+
+// get performs the request built for the next healthy key, backing off longer
+// than the session does once every key is rate limited since the quota of the
+// public api is counted per minute
+func (s *Source) get(ctx context.Context, session *subscraping.Session, request func(apiKey string) (string, map[string]string), refused ...int) (*http.Response, error) {
+	ctx = subscraping.WithRetryPolicy(ctx, subscraping.RetryPolicy{MaxRetries: maxRetries, BaseDelay: s.getBackoff(), MaxDelay: maxBackoff})
+	resp, err := session.DoWithKeys(ctx, s.Name(), s.apiKeys, func(ctx context.Context, apiKey string) (*http.Response, error) {
+		api, headers := request(apiKey)
+		return session.Get(ctx, api, "", headers)
+	}, refused...)
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ValidateKey reads the overall quotas of the key, which does not count against them
+func (s *Source) ValidateKey(ctx context.Context, key string, session *subscraping.Session) (subscraping.KeyProbe, error) {
+	api := fmt.Sprintf("%s/api/v3/users/%s/overall_quotas", s.GetBaseURL(defaultBaseURL), url.PathEscape(key))
+	resp, err := session.Get(subscraping.WithoutRetry(ctx), api, "", map[string]string{"x-apikey": key})
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return subscraping.KeyProbe{}, nil
+		}
+		return subscraping.KeyProbe{}, err
+	}
+	defer resp.Body.Close()
+
+	var quotas quotasResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&quotas); err != nil {
+		return subscraping.KeyProbe{}, err
+	}
+	probe := subscraping.KeyProbe{Valid: true}
+	if daily, ok := quotas.Data["api_requests_daily"]; ok {
+		probe.Quota = fmt.Sprintf("%d/%d requests today", daily.User.Allowed-daily.User.Used, daily.User.Allowed)
+	}
+	return probe, nil
+}
+
+func (s *Source) getBackoff() time.Duration {
+	if s.backoff > 0 {
+		return s.backoff
+	}
+	return defaultBackoff
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return "virustotal"
+}
+
+func (s *Source) IsDefault() bool {
+	return true
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return false
+}
+
+func (s *Source) NeedsKey() bool {
+	return true
+}
+
+// RateLimit returns the quota of the public api: 4 requests per minute and 500 per day
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 4.0 / 60, PerDay: 500, MaxInFlight: 1}
+}
+
+func (s *Source) AddApiKeys(keys []string) {
+	s.apiKeys = keys
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+		Skipped:   s.skipped,
+	}
+}
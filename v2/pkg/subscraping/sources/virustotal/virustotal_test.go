@@ -0,0 +1,126 @@
+package virustotal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/chainreactors/urlfounder/v2/pkg/testutils"
+)
+
+func newSource(t *testing.T, handler http.HandlerFunc, keys ...string) *Source {
+	source := &Source{backoff: time.Millisecond}
+	source.SetBaseURL(testutils.NewSourceServer(t, handler).URL)
+	source.AddApiKeys(keys)
+	return source
+}
+
+func TestRunCursorWithBackoff(t *testing.T) {
+	throttled := false
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v3/domains/example.com/urls", r.URL.Path)
+		require.Equal(t, "test-key", r.Header.Get("x-apikey"))
+
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = fmt.Fprint(w, `{"data": [{"attributes": {"url": "https://example.com/a", "last_http_response_code": 200}}], "meta": {"cursor": "next"}}`)
+		case "next":
+			if !throttled {
+				throttled = true
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"data": [{"attributes": {"url": "https://example.com/b"}}], "meta": {}}`)
+		}
+	}, "test-key")
+
+	ctx := subscraping.WithProgress(context.Background(), subscraping.Progress{})
+	var urls, cursors []string
+	for _, result := range testutils.RunSource(ctx, source, "example.com", testutils.NewSession(t, "example.com")) {
+		switch result.Type {
+		case subscraping.URL:
+			urls = append(urls, result.Value)
+		case subscraping.Cursor:
+			cursors = append(cursors, result.Value)
+		default:
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+	}
+	require.Equal(t, []string{"https://example.com/a", "https://example.com/b"}, urls)
+	require.Equal(t, []string{"next"}, cursors)
+	require.True(t, throttled, "the rate limited page is requested again")
+}
+
+func TestRunReportFallback(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vtapi/v2/domain/report":
+			require.Equal(t, "test-key", r.URL.Query().Get("apikey"))
+			_, _ = fmt.Fprint(w, `{"detected_urls": [{"url": "https://example.com/bad", "scan_date": "2020-01-01 00:00:00"}],
+				"undetected_urls": [["https://example.com/good", "abc", 0, 70, "2021-01-01 00:00:00"]]}`)
+		default:
+			// the relationship is forbidden to the keys of the public api
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}, "test-key")
+
+	results := testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Equal(t, []string{"https://example.com/bad", "https://example.com/good"}, testutils.URLs(t, results))
+	require.Equal(t, "true", results[0].Metadata.Extra["detected"])
+}
+
+func TestRunNoFallbackAfterResults(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/vtapi/v2/domain/report":
+			t.Errorf("the report should not be read once the relationship returned urls")
+		case r.URL.Query().Get("cursor") == "":
+			_, _ = fmt.Fprint(w, `{"data": [{"attributes": {"url": "https://example.com/a"}}], "meta": {"cursor": "next"}}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}, "test-key")
+
+	results := testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Len(t, results, 2)
+	require.Equal(t, subscraping.Error, results[1].Type)
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestRunErrorHidesKey(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}, "secret-key")
+
+	results := testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Len(t, results, 1)
+	require.Equal(t, subscraping.Error, results[0].Type)
+	require.Contains(t, results[0].Error.Error(), "/vtapi/v2/domain/report")
+	require.NotContains(t, results[0].Error.Error(), "secret-key")
+}
+
+func TestValidateKey(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-apikey") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.Equal(t, "/api/v3/users/test-key/overall_quotas", r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"data": {"api_requests_daily": {"user": {"used": 20, "allowed": 500}}}}`)
+	})
+	session := testutils.NewSession(t, "")
+
+	probe, err := source.ValidateKey(context.Background(), "test-key", session)
+	require.Nil(t, err)
+	require.Equal(t, subscraping.KeyProbe{Valid: true, Quota: "480/500 requests today"}, probe)
+
+	probe, err = source.ValidateKey(context.Background(), "wrong-key", session)
+	require.Nil(t, err)
+	require.False(t, probe.Valid)
+}
@@ -0,0 +1,181 @@
+package waybackrobots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+const (
+	defaultBaseURL = "https://web.archive.org"
+	// maxBodySize caps the amount of data read from a single archived robots.txt
+	maxBodySize = 1024 * 1024
+)
+
+// snapshot is a unique archived version of robots.txt
+type snapshot struct {
+	timestamp string
+	original  string
+}
+
+// Source is the passive scraping agent
+type Source struct {
+	// BaseURL is the root of the wayback machine api, overridden in tests
+	subscraping.BaseURL
+	timeTaken time.Duration
+	errors    int
+	results   int
+}
+
+// Run function returns all urls found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		snapshots, err := s.listSnapshots(ctx, domain, session)
+		if err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+			return
+		}
+
+		// snapshots are listed oldest first, so each url is tagged with the
+		// first snapshot it appeared in
+		seen := make(map[string]struct{})
+		for _, snap := range snapshots {
+			if ctx.Err() != nil {
+				return
+			}
+
+			robots, err := s.fetchSnapshot(ctx, snap, session)
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				s.errors++
+				continue
+			}
+
+			base, err := url.Parse(snap.original)
+			if err != nil || base.Host == "" {
+				continue
+			}
+			for _, path := range robots.Paths {
+				value := fmt.Sprintf("%s://%s%s", base.Scheme, base.Host, path)
+				if _, ok := seen[value]; ok {
+					continue
+				}
+				seen[value] = struct{}{}
+				// the path is dated by the first robots.txt snapshot listing it
+				metadata := &subscraping.Metadata{Extra: map[string]string{"robots_snapshot": snap.timestamp}}
+				metadata.Seen(subscraping.ParseTime(snap.timestamp))
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: value, Metadata: metadata}
+				s.results++
+			}
+		}
+	}()
+
+	return results
+}
+
+// listSnapshots returns the archived robots.txt captures with a unique digest
+func (s *Source) listSnapshots(ctx context.Context, domain string, session *subscraping.Session) ([]snapshot, error) {
+	params := url.Values{}
+	params.Set("url", domain+"/robots.txt")
+	params.Set("output", "json")
+	params.Set("fl", "timestamp,original,digest")
+	params.Set("filter", "statuscode:200")
+	params.Set("collapse", "digest")
+	// only the robots.txt archived within the date range are read
+	from, to := session.Dates.Timestamps()
+	if from != "" {
+		params.Set("from", from)
+	}
+	if to != "" {
+		params.Set("to", to)
+	}
+
+	resp, err := session.SimpleGet(ctx, fmt.Sprintf("%s/cdx/search/cdx?%s", s.GetBaseURL(defaultBaseURL), params.Encode()))
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rows [][]string
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var snapshots []snapshot
+	digests := make(map[string]struct{})
+	for i, row := range rows {
+		// the first row is the field header
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+		if _, ok := digests[row[2]]; ok {
+			continue
+		}
+		digests[row[2]] = struct{}{}
+		snapshots = append(snapshots, snapshot{timestamp: row[0], original: row[1]})
+	}
+	return snapshots, nil
+}
+
+// fetchSnapshot downloads the raw archived robots.txt through the id_ flag,
+// which serves the capture without the wayback toolbar and link rewriting
+func (s *Source) fetchSnapshot(ctx context.Context, snap snapshot, session *subscraping.Session) (subscraping.Robots, error) {
+	resp, err := session.SimpleGet(ctx, fmt.Sprintf("%s/web/%sid_/%s", s.GetBaseURL(defaultBaseURL), snap.timestamp, snap.original))
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return subscraping.Robots{}, err
+	}
+	defer resp.Body.Close()
+
+	return subscraping.ParseRobots(io.LimitReader(resp.Body, maxBodySize)), nil
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return "waybackrobots"
+}
+
+func (s *Source) IsDefault() bool {
+	return false
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return true
+}
+
+func (s *Source) AddApiKeys(_ []string) {
+	// no key needed
+}
+
+func (s *Source) NeedsKey() bool {
+	return false
+}
+
+// RateLimit keeps below the rate at which the wayback machine starts refusing requests
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 1, MaxInFlight: 2}
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+	}
+}
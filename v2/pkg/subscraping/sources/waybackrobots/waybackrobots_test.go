@@ -0,0 +1,80 @@
+package waybackrobots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+	"github.com/chainreactors/urlfounder/v2/pkg/testutils"
+)
+
+func newSource(t *testing.T, handler http.HandlerFunc) *Source {
+	source := &Source{}
+	source.SetBaseURL(testutils.NewSourceServer(t, handler).URL)
+	return source
+}
+
+func TestRunSnapshots(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cdx/search/cdx":
+			require.Equal(t, "example.com/robots.txt", r.URL.Query().Get("url"))
+			_, _ = fmt.Fprint(w, `[["timestamp","original","digest"],
+				["20150101000000","http://example.com/robots.txt","AAA"],
+				["20180101000000","https://example.com/robots.txt","BBB"],
+				["20190101000000","https://example.com/robots.txt","AAA"]]`)
+		case "/web/20150101000000id_/http://example.com/robots.txt":
+			_, _ = fmt.Fprint(w, "User-agent: *\nDisallow: /old-admin/\nDisallow: /api/\n")
+		case "/web/20180101000000id_/https://example.com/robots.txt":
+			_, _ = fmt.Fprint(w, "User-agent: *\nDisallow: /api/\n")
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	results := testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Equal(t, []string{"http://example.com/old-admin/", "http://example.com/api/", "https://example.com/api/"}, testutils.URLs(t, results))
+	require.Equal(t, time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), results[0].Metadata.FirstSeen)
+	require.Equal(t, "20180101000000", results[2].Metadata.Extra["robots_snapshot"])
+}
+
+func TestRunSnapshotError(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cdx/search/cdx":
+			_, _ = fmt.Fprint(w, `[["timestamp","original","digest"],
+				["20150101000000","http://example.com/robots.txt","AAA"],
+				["20180101000000","http://example.com/robots.txt","BBB"]]`)
+		case "/web/20150101000000id_/http://example.com/robots.txt":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = fmt.Fprint(w, "User-agent: *\nDisallow: /api/\n")
+		}
+	})
+
+	results := testutils.RunSource(context.Background(), source, "example.com", testutils.NewSession(t, "example.com"))
+	require.Len(t, results, 2)
+	require.Equal(t, subscraping.Error, results[0].Type)
+	require.Equal(t, "http://example.com/api/", results[1].Value, "the snapshots after a failed one are still read")
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestRunDateRange(t *testing.T) {
+	source := newSource(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "20200101000000", r.URL.Query().Get("from"))
+		require.Equal(t, "20211231000000", r.URL.Query().Get("to"))
+		_, _ = fmt.Fprint(w, `[["timestamp","original","digest"]]`)
+	})
+
+	session := testutils.NewSession(t, "example.com")
+	session.Dates = subscraping.DateRange{
+		Since: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	require.Empty(t, testutils.RunSource(context.Background(), source, "example.com", session))
+}
@@ -3,14 +3,30 @@ package webarchive
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
 )
 
+const (
+	defaultBaseURL  = "https://web.archive.org"
+	defaultPageSize = 1000
+	fields          = "original,mimetype,timestamp,endtimestamp,groupcount,uniqcount,statuscode"
+)
+
+// errForbidden is returned when the archive refuses the request, which is
+// not reported as a source error
+var errForbidden = errors.New("forbidden")
+
 // Source is the passive scraping agent
 type Source struct {
+	// BaseURL is the root of the wayback machine api, overridden in tests
+	subscraping.BaseURL
 	timeTaken time.Duration
 	errors    int
 	results   int
@@ -29,42 +45,142 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		headers := map[string]string{
-			"Accept":       "application/json",
-			"Content-Type": "application/json",
+		pageSize := session.PageSize
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
 		}
 
-		api := fmt.Sprintf("https://web.archive.org/web/timemap/json?url=%s&matchType=prefix&collapse=urlkey&output=json&fl=original%%2Cmimetype%%2Ctimestamp%%2Cendtimestamp%%2Cgroupcount%%2Cuniqcount&limit=1000", domain)
-		resp, err := session.Get(ctx, api, "", headers)
-		isForbidden := resp != nil && resp.StatusCode == http.StatusForbidden
-		if err != nil {
-			//println(err.Error())
-			if !isForbidden {
-				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
-				s.errors++
-				session.DiscardHTTPResponse(resp)
+		resumeKey := subscraping.ResumeCursor(ctx, s.Name())
+		for page := 0; session.MaxPages <= 0 || page < session.MaxPages; page++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			rows, nextKey, err := s.fetchPage(ctx, session, domain, pageSize, resumeKey)
+			if err != nil {
+				if err != errForbidden {
+					results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+					s.errors++
+				}
+				return
+			}
+
+			for _, r := range rows {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: r[0], Metadata: parseMetadata(r)}
+				s.results++
+			}
+
+			if nextKey == "" {
+				return
+			}
+			resumeKey = nextKey
+			if subscraping.Resumable(ctx) {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Cursor, Value: resumeKey}
 			}
-			return
 		}
+	}()
+
+	return results
+}
+
+// fetchPage requests a single page of the timemap and returns its rows
+// along with the resume key of the next page, if any.
+func (s *Source) fetchPage(ctx context.Context, session *subscraping.Session, domain string, pageSize int, resumeKey string) ([][]string, string, error) {
+	headers := map[string]string{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+	}
+
+	params := url.Values{}
+	params.Set("url", domain)
+	params.Set("matchType", "prefix")
+	params.Set("collapse", "urlkey")
+	params.Set("output", "json")
+	params.Set("fl", fields)
+	params.Set("limit", strconv.Itoa(pageSize))
+	params.Set("showResumeKey", "true")
+	setDateRange(params, session.Dates)
+	if resumeKey != "" {
+		params.Set("resumeKey", resumeKey)
+	}
 
-		var res [][]string
-		err = json.NewDecoder(resp.Body).Decode(&res)
-		if err != nil {
-			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
-			s.errors++
-			resp.Body.Close()
-			return
+	api := fmt.Sprintf("%s/web/timemap/json?%s", s.GetBaseURL(defaultBaseURL), params.Encode())
+	resp, err := session.Get(ctx, api, "", headers)
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, "", errForbidden
 		}
-		resp.Body.Close()
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var res [][]string
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, "", err
+	}
+	rows, nextKey := parseRows(res)
+	return rows, nextKey, nil
+}
+
+// parseRows strips the header row and the trailing resume key from a
+// timemap response. When showResumeKey is set the server terminates the
+// result rows with an empty row followed by a single element row holding
+// the key for the next page.
+func parseRows(res [][]string) ([][]string, string) {
+	if len(res) == 0 {
+		return nil, ""
+	}
+	res = res[1:]
 
-		for _, r := range res[1:] {
-			results <- subscraping.Result{Source: s.Name(), Type: subscraping.URL, Value: r[0]}
-			s.results++
+	var rows [][]string
+	for i, r := range res {
+		if len(r) == 0 {
+			if i+1 < len(res) && len(res[i+1]) > 0 {
+				return rows, res[i+1][0]
+			}
+			return rows, ""
 		}
+		rows = append(rows, r)
+	}
+	return rows, ""
+}
 
-	}()
+// setDateRange restricts the captures listed to the date range
+func setDateRange(params url.Values, dates subscraping.DateRange) {
+	from, to := dates.Timestamps()
+	if from != "" {
+		params.Set("from", from)
+	}
+	if to != "" {
+		params.Set("to", to)
+	}
+}
 
-	return results
+// parseMetadata reads the fields of a timemap row following the original url,
+// the row of an url collapsed by urlkey spans all of its captures
+func parseMetadata(row []string) *subscraping.Metadata {
+	field := func(i int) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	metadata := &subscraping.Metadata{StatusCode: subscraping.ParseStatusCode(field(6))}
+	// revisit records and unknown types don't tell the type of the content
+	if mime := field(1); mime != "unk" && mime != "warc/revisit" {
+		metadata.Mime = mime
+	}
+	metadata.Seen(subscraping.ParseTime(field(2)))
+	metadata.Seen(subscraping.ParseTime(field(3)))
+	metadata.Hits, _ = strconv.Atoi(field(4))
+	if uniqcount := field(5); uniqcount != "" {
+		metadata.Extra = map[string]string{"uniqcount": uniqcount}
+	}
+	return metadata
 }
 
 // Name returns the name of the source
@@ -88,6 +204,11 @@ func (s *Source) NeedsKey() bool {
 	return true
 }
 
+// RateLimit keeps below the rate at which the cdx server starts refusing requests
+func (s *Source) RateLimit() subscraping.RateLimit {
+	return subscraping.RateLimit{PerSecond: 1, MaxInFlight: 2}
+}
+
 func (s *Source) Statistics() subscraping.Statistics {
 	return subscraping.Statistics{
 		Errors:    s.errors,
@@ -0,0 +1,147 @@
+package webarchive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+func newTestServer(t *testing.T, pages int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/web/timemap/json", r.URL.Path)
+		require.Equal(t, "true", r.URL.Query().Get("showResumeKey"))
+
+		page := 0
+		if key := r.URL.Query().Get("resumeKey"); key != "" {
+			_, _ = fmt.Sscanf(key, "key-%d", &page)
+		}
+
+		rows := [][]string{{"original", "mimetype", "timestamp", "endtimestamp", "groupcount", "uniqcount"}}
+		for i := 0; i < 2; i++ {
+			rows = append(rows, []string{fmt.Sprintf("https://example.com/%d/%d", page, i), "text/html", "20200101000000", "20210101000000", "1", "1"})
+		}
+		if page+1 < pages {
+			rows = append(rows, []string{}, []string{fmt.Sprintf("key-%d", page+1)})
+		}
+		_ = json.NewEncoder(w).Encode(rows)
+	}))
+}
+
+func TestParseRows(t *testing.T) {
+	rows, key := parseRows([][]string{{"original"}, {"a"}, {"b"}, {}, {"next"}})
+	require.Equal(t, [][]string{{"a"}, {"b"}}, rows)
+	require.Equal(t, "next", key)
+
+	rows, key = parseRows([][]string{{"original"}, {"a"}})
+	require.Equal(t, [][]string{{"a"}}, rows)
+	require.Empty(t, key)
+
+	rows, key = parseRows(nil)
+	require.Empty(t, rows)
+	require.Empty(t, key)
+}
+
+func TestSetDateRange(t *testing.T) {
+	params := url.Values{}
+	setDateRange(params, subscraping.DateRange{})
+	require.Empty(t, params)
+
+	setDateRange(params, subscraping.DateRange{Since: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)})
+	require.Equal(t, url.Values{"from": {"20180101000000"}}, params)
+}
+
+func TestParseMetadata(t *testing.T) {
+	metadata := parseMetadata([]string{"https://example.com/a", "text/html", "20200101000000", "20210101000000", "4", "2", "200"})
+	require.Equal(t, &subscraping.Metadata{
+		FirstSeen:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode: 200,
+		Mime:       "text/html",
+		Hits:       4,
+		Extra:      map[string]string{"uniqcount": "2"},
+	}, metadata)
+
+	metadata = parseMetadata([]string{"https://example.com/a", "warc/revisit", "-", "", "", "", "-"})
+	require.Equal(t, &subscraping.Metadata{}, metadata)
+
+	require.Equal(t, &subscraping.Metadata{}, parseMetadata([]string{"https://example.com/a"}))
+}
+
+func TestRunPagination(t *testing.T) {
+	server := newTestServer(t, 3)
+	defer server.Close()
+
+	t.Run("all pages", func(t *testing.T) {
+		session, err := subscraping.NewSession("example.com", "", 0, 10)
+		require.Nil(t, err)
+
+		source := &Source{}
+		source.SetBaseURL(server.URL)
+		var urls []string
+		for result := range source.Run(context.Background(), "example.com", session) {
+			require.Equal(t, subscraping.URL, result.Type, "unexpected error: %v", result.Error)
+			urls = append(urls, result.Value)
+		}
+		require.Len(t, urls, 6)
+		require.Equal(t, 6, source.Statistics().Results)
+	})
+
+	t.Run("max pages", func(t *testing.T) {
+		session, err := subscraping.NewSession("example.com", "", 0, 10)
+		require.Nil(t, err)
+		session.MaxPages = 2
+
+		source := &Source{}
+		source.SetBaseURL(server.URL)
+		var urls []string
+		for result := range source.Run(context.Background(), "example.com", session) {
+			urls = append(urls, result.Value)
+		}
+		require.Len(t, urls, 4)
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		session, err := subscraping.NewSession("example.com", "", 0, 10)
+		require.Nil(t, err)
+
+		ctx := subscraping.WithProgress(context.Background(), subscraping.Progress{Cursors: map[string]string{"webarchive": "key-1"}})
+		source := &Source{}
+		source.SetBaseURL(server.URL)
+		var urls, cursors []string
+		for result := range source.Run(ctx, "example.com", session) {
+			switch result.Type {
+			case subscraping.URL:
+				urls = append(urls, result.Value)
+			case subscraping.Cursor:
+				cursors = append(cursors, result.Value)
+			}
+		}
+		require.Equal(t, []string{"https://example.com/1/0", "https://example.com/1/1", "https://example.com/2/0", "https://example.com/2/1"}, urls)
+		require.Equal(t, []string{"key-2"}, cursors, "the last page has no next one")
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		session, err := subscraping.NewSession("example.com", "", 0, 10)
+		require.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		source := &Source{}
+		source.SetBaseURL(server.URL)
+		var urls []string
+		for result := range source.Run(ctx, "example.com", session) {
+			urls = append(urls, result.Value)
+		}
+		require.Empty(t, urls)
+	})
+}
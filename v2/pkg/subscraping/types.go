@@ -5,8 +5,6 @@ import (
 	"net/http"
 	"regexp"
 	"time"
-
-	"github.com/projectdiscovery/ratelimit"
 )
 
 // BasicAuth request's Authorization header
@@ -20,7 +18,10 @@ type Statistics struct {
 	TimeTaken time.Duration
 	Errors    int
 	Results   int
+	Retries   int
 	Skipped   bool
+	// Cache tells whether the results were read from the cache
+	Cache CacheStatus
 }
 
 // Source is an interface inherited by each passive source
@@ -51,6 +52,41 @@ type Source interface {
 	Statistics() Statistics
 }
 
+// KeyProbe is the outcome of checking an api key against its service
+type KeyProbe struct {
+	// Valid is true if the service accepted the key
+	Valid bool
+	// Quota is the remaining quota reported by the service, empty when unknown
+	Quota string
+}
+
+// KeyValidator is implemented by the sources able to check an api
+// key with a cheap authenticated request
+type KeyValidator interface {
+	// ValidateKey probes the service with the key, an error is
+	// returned when the service could not tell whether the key works
+	ValidateKey(context.Context, string, *Session) (KeyProbe, error)
+}
+
+// BaseURL is embedded by the sources querying the api of a service, the
+// root of the api is overridden to query another server such as in tests
+type BaseURL struct {
+	baseURL string
+}
+
+// SetBaseURL overrides the root of the api queried by the source
+func (b *BaseURL) SetBaseURL(baseURL string) {
+	b.baseURL = baseURL
+}
+
+// GetBaseURL returns the root of the api, the default one unless overridden
+func (b *BaseURL) GetBaseURL(defaultURL string) string {
+	if b.baseURL != "" {
+		return b.baseURL
+	}
+	return defaultURL
+}
+
 // Session is the option passed to the source, an option is created
 // uniquely for each source.
 type Session struct {
@@ -58,8 +94,28 @@ type Session struct {
 	Extractor *regexp.Regexp
 	// Client is the current http client
 	Client *http.Client
-	// Rate limit instance
-	RateLimiter *ratelimit.Limiter
+	// Rate limit instance, shared with the other sessions of the source
+	RateLimiter *Limiter
+	// PageSize is the number of results requested per page from paginated sources,
+	// sources fall back to their own default when it is zero
+	PageSize int
+	// MaxPages caps the number of pages fetched from paginated sources, zero means no cap
+	MaxPages int
+	// MaxIndexes is the number of most recent crawl indexes queried by index based sources
+	MaxIndexes int
+	// YearFrom and YearTo restrict the crawl indexes to a year range, zero means unbounded
+	YearFrom int
+	YearTo   int
+	// Dates restricts the results to the urls seen in a date range,
+	// sources able to query a range push it down to their service
+	Dates DateRange
+	// Retry is the retry policy of the requests, a request may
+	// override it with WithRetryPolicy or WithoutRetry
+	Retry RetryPolicy
+	// Keys tracks the health of the api keys, shared with the other sessions of the run
+	Keys *KeyManager
+	// retries is the number of requests retried, updated atomically
+	retries int32
 }
 
 // Result is a result structure returned by a source
@@ -68,6 +124,8 @@ type Result struct {
 	Source string
 	Value  string
 	Error  error
+	// Metadata holds what the source knows about the url, nil when it knows nothing
+	Metadata *Metadata
 }
 
 // ResultType is the type of result returned by the source
@@ -77,4 +135,9 @@ type ResultType int
 const (
 	URL ResultType = iota
 	Error
+	// Cursor holds the position of the next page of the source once the
+	// urls of the previous pages were returned
+	Cursor
+	// Done is returned once the source returned all of its results
+	Done
 )
@@ -23,7 +23,7 @@ func init() {
 func NewURLExtractor(domain string) (*regexp.Regexp, error) {
 	urlExtractorMutex.Lock()
 	defer urlExtractorMutex.Unlock()
-	extractor, err := regexp.Compile(`[a-zA-Z0-9\*_.-]+\.` + domain)
+	extractor, err := regexp.Compile(`[a-zA-Z0-9\*_.-]+\.` + regexp.QuoteMeta(domain))
 	if err != nil {
 		return nil, err
 	}
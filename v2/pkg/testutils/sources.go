@@ -0,0 +1,48 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/chainreactors/urlfounder/v2/pkg/subscraping"
+)
+
+// NewSourceServer starts a server standing for the service of a source,
+// it is closed with the test
+func NewSourceServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// NewSession creates a session for the domain, failed requests are not
+// retried so that the sources see the errors of the server right away
+func NewSession(t *testing.T, domain string) *subscraping.Session {
+	session, err := subscraping.NewSession(domain, "", 0, 10)
+	require.Nil(t, err)
+	session.Retry = subscraping.RetryPolicy{}
+	return session
+}
+
+// RunSource runs the source on the domain and returns all its results
+func RunSource(ctx context.Context, source subscraping.Source, domain string, session *subscraping.Session) []subscraping.Result {
+	var results []subscraping.Result
+	for result := range source.Run(ctx, domain, session) {
+		results = append(results, result)
+	}
+	return results
+}
+
+// URLs returns the urls of the results, failing the test on any other result
+func URLs(t *testing.T, results []subscraping.Result) []string {
+	var urls []string
+	for _, result := range results {
+		require.Equal(t, subscraping.URL, result.Type, "unexpected result %s: %v", result.Value, result.Error)
+		urls = append(urls, result.Value)
+	}
+	return urls
+}